@@ -1,37 +1,125 @@
 package provider
 
 import (
+	"errors"
+	"github.com/hashicorp/terraform/helper/logging"
 	"github.com/hashicorp/terraform/helper/schema"
+	"os"
+	"servers.com/terraform-provider/serverscom"
+	"strings"
+	"time"
 )
 
-type Config  struct {
-	Url			  string
-	Email     	  string
-	Pwd     	  string
+type Config struct {
+	Url          string
+	Email        string
+	Pwd          string
+	Token        string
+	Profile      string
+	CredsFile    string
+	MaxRetries   int
+	MaxRetryWait time.Duration
+	MaxRPS       float64
+	Transport    serverscom.TransportConfig
 }
 
-
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 	config := Config{
-		Url: d.Get("url").(string),
+		Url:   d.Get("url").(string),
 		Email: d.Get("email").(string),
-		Pwd: d.Get("password").(string),
+		Pwd:   d.Get("password").(string),
+		Token: d.Get("token").(string),
+
+		Profile:   d.Get("profile").(string),
+		CredsFile: d.Get("credentials_file").(string),
+
+		MaxRetries:   d.Get("max_retries").(int),
+		MaxRetryWait: time.Duration(d.Get("max_retry_wait").(int)) * time.Second,
+		MaxRPS:       d.Get("max_requests_per_second").(float64),
+
+		Transport: serverscom.TransportConfig{
+			CAFile:             d.Get("ca_file").(string),
+			ClientCertFile:     d.Get("client_cert_file").(string),
+			ClientKeyFile:      d.Get("client_key_file").(string),
+			InsecureSkipVerify: d.Get("insecure_skip_verify").(bool),
+			ProxyURL:           d.Get("proxy_url").(string),
+		},
+	}
+	if err := config.applyProfile(); err != nil {
+		return nil, err
 	}
 	return config.Client()
 }
 
-type Client struct {
-	Url			  string
-	Email     	  string
-	Token         string
-	Pwd     	  string
+// applyProfile fills in url, email and credentials that were set neither in
+// the configuration nor in the environment from the credentials file. The
+// default file is optional, but a profile or file named explicitly must exist.
+func (c *Config) applyProfile() error {
+	explicit := c.Profile != "" || c.CredsFile != ""
+	complete := c.Url != "" && c.Email != "" && (c.Pwd != "" || c.Token != "")
+	if complete && !explicit {
+		return nil
+	}
+	path := c.CredsFile
+	if path == "" {
+		path = serverscom.DefaultCredentialsFile()
+	}
+	profile := c.Profile
+	if profile == "" {
+		profile = serverscom.DefaultProfile
+	}
+	creds, err := serverscom.LoadCredentials(path, profile)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return nil
+		}
+		return err
+	}
+	if c.Url == "" {
+		c.Url = creds.URL
+	}
+	if c.Email == "" {
+		c.Email = creds.Email
+	}
+	if c.Pwd == "" && c.Token == "" {
+		c.Pwd = creds.Password
+		c.Token = creds.Token
+	}
+	return nil
 }
 
-func (c *Config) Client() (interface{}, error) {
-	t, err := GetToken(c.Url, c.Email, c.Pwd)
+func (c *Config) Client() (*serverscom.Client, error) {
+	if c.Url == "" {
+		return nil, errors.New("Provider url must be set.")
+	}
+	if c.Email == "" {
+		return nil, errors.New("Provider email must be set.")
+	}
+	client := serverscom.NewClient(c.Url, c.Email, c.Pwd)
+	client.MaxRetries = c.MaxRetries
+	client.MaxRetryWait = c.MaxRetryWait
+	client.SetRateLimit(c.MaxRPS)
+	transport, err := serverscom.NewTransport(c.Transport)
+	if err != nil {
+		return nil, err
+	}
+	client.HTTPClient.Transport, err = serverscom.NewTransportFromEnv(transport)
 	if err != nil {
 		return nil, err
 	}
-	client := &Client{Url:c.Url, Email:c.Email, Token:t, Pwd:c.Pwd}
+	if logging.IsDebugOrHigher() {
+		client.HTTPClient.Transport = serverscom.NewLoggingTransport(client.HTTPClient.Transport,
+			strings.EqualFold(logging.CurrentLogLevel(), "TRACE"))
+	}
+	if c.Token != "" {
+		client.SetToken(c.Token)
+		return client, nil
+	}
+	if c.Pwd == "" {
+		return nil, errors.New("Either password or token must be set.")
+	}
+	if err = client.Login(); err != nil {
+		return nil, err
+	}
 	return client, nil
 }
@@ -2,7 +2,11 @@ package provider
 
 import (
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
+	"math"
+	"servers.com/terraform-provider/serverscom"
+	"time"
 )
 
 func Provider() terraform.ResourceProvider {
@@ -10,20 +14,103 @@ func Provider() terraform.ResourceProvider {
 		Schema: map[string]*schema.Schema{
 			"url": {
 				Type:        schema.TypeString,
-				Required:    true,
-				Description: descriptions["Please provide url."],
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SERVERSCOM_URL", nil),
+				Description: "Portal URL. Can also be set with SERVERSCOM_URL.",
 			},
 
 			"email": {
 				Type:        schema.TypeString,
-				Required:    true,
-				Description: descriptions["Please provide email/login."],
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SERVERSCOM_EMAIL", nil),
+				Description: "Account email/login. Can also be set with SERVERSCOM_EMAIL.",
 			},
 
 			"password": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("SERVERSCOM_PASSWORD", nil),
+				ConflictsWith: []string{"token"},
+				Description:   "Account password. Can also be set with SERVERSCOM_PASSWORD.",
+			},
+
+			"token": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("SERVERSCOM_TOKEN", nil),
+				ConflictsWith: []string{"password"},
+				Description:   "API token sent as X-User-Token instead of logging in with the password. Releasing servers still needs the password. Can also be set with SERVERSCOM_TOKEN.",
+			},
+
+			"profile": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SERVERSCOM_PROFILE", ""),
+				Description: "Profile in the credentials file that fills in url, email, password and token when they are not set otherwise.",
+			},
+
+			"credentials_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SERVERSCOM_CREDENTIALS_FILE", ""),
+				Description: "Path to the credentials file. Defaults to ~/.serverscom/credentials.",
+			},
+
+			"ca_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM bundle of additional CAs trusted for the API endpoint.",
+			},
+
+			"client_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM client certificate for gateways that require mutual TLS.",
+			},
+
+			"client_key_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM private key for client_cert_file.",
+			},
+
+			"insecure_skip_verify": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip verification of the API certificate. Only for lab stand-ins of the API.",
+			},
+
+			"proxy_url": {
 				Type:        schema.TypeString,
-				Required:    true,
-				Description: descriptions["Please provide password."],
+				Optional:    true,
+				Description: "HTTP proxy for API requests. Defaults to HTTPS_PROXY/HTTP_PROXY from the environment.",
+			},
+
+			"max_requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Default:      0.0,
+				ValidateFunc: validation.FloatBetween(0, math.MaxFloat64),
+				Description:  "Limit on API requests per second shared by all resources. 0 means no limit.",
+			},
+
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      serverscom.DefaultMaxRetries,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How many times a request failing with 429, 5xx or a connection error is retried.",
+			},
+
+			"max_retry_wait": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(serverscom.DefaultMaxRetryWait / time.Second),
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Maximum number of seconds to wait before a retry.",
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
@@ -34,5 +121,3 @@ func Provider() terraform.ResourceProvider {
 		ConfigureFunc: providerConfigure,
 	}
 }
-
-var descriptions map[string]string
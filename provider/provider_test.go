@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"servers.com/terraform-provider/serverscom"
+	"servers.com/terraform-provider/serverscom/serverscomtest"
+	"testing"
+	"time"
+)
+
+var testAccProvider *schema.Provider
+var testAccProviders map[string]terraform.ResourceProvider
+
+func init() {
+	testAccProvider = Provider().(*schema.Provider)
+	testAccProviders = map[string]terraform.ResourceProvider{
+		"serverscom": testAccProvider,
+	}
+}
+
+func TestProvider(t *testing.T) {
+	if err := Provider().(*schema.Provider).InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testAccFake starts a fake portal for one test and points the provider at
+// it. The fake advances on every request, so orders and segments settle
+// while the provider polls.
+func testAccFake(t *testing.T) *serverscomtest.Server {
+	interval := serverPollInterval
+	t.Cleanup(func() { serverPollInterval = interval })
+	serverPollInterval = 10 * time.Millisecond
+	fake := serverscomtest.NewServer("test@example.com", "secret")
+	fake.AutoAdvance = true
+	t.Setenv("SERVERSCOM_URL", fake.URL)
+	t.Setenv("SERVERSCOM_EMAIL", fake.Email)
+	t.Setenv("SERVERSCOM_PASSWORD", fake.Password)
+	t.Setenv("SERVERSCOM_TOKEN", "")
+	t.Setenv("SERVERSCOM_PROFILE", "")
+	t.Setenv("SERVERSCOM_CREDENTIALS_FILE", "")
+	t.Setenv("SERVERSCOM_CASSETTE", "")
+	return fake
+}
+
+// testAccClient logs in to the fake outside the provider, for checks and
+// for changes made behind Terraform's back.
+func testAccClient(t *testing.T, fake *serverscomtest.Server) *serverscom.Client {
+	client, err := fake.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
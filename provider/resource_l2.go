@@ -1,112 +1,27 @@
 package provider
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
-	"strings"
+	"log"
+	"servers.com/terraform-provider/serverscom"
+	"sort"
 )
 
-type L2DataListResp struct {
-	Data *[]L2Resp `json:"data"`
-}
-
-type L2DataResp struct {
-	Data *L2Resp `json:"data"`
-}
-
-type L2Resp struct {
-	Id         int           `json:"id"`
-	Hosts      *[]L2HostResp `json:"hosts"`
-	Location   interface{}   `json:"location"`
-	Name       string        `json:"name"`
-	Status     string        `json:"status"`
-	Type       int           `json:"type"`
-}
-
-type L2HostResp struct {
-	Id     int           `json:"id"`
-	Mode   string        `json:"mode"`
-	Title  string        `json:"title"`
-	Vlan   interface{}   `json:"vlan"`
-}
-
-func ListL2(url, email, token string) (*[]L2Resp, error) {
-	body, err := GetResponse(fmt.Sprintf("%s/rest/l2_segments", url),
-		email, token, "GET", nil)
-	if err != nil {
-		return nil, err
-	}
-	var l2Data L2DataListResp
-	err = json.Unmarshal([]byte(string(*body)), &l2Data)
-	if err != nil {
-		return nil, err
-	}
-	fmt.Println(fmt.Sprintf("Segments len: %d", len(*l2Data.Data)))
-	for _, l2 := range *l2Data.Data {
-		fmt.Println(fmt.Sprintf("Id: %d, Status: %s", l2.Id, l2.Status))
-	}
-	return l2Data.Data, nil
-}
-
-type L2Req struct {
-	DeleteIps   interface{}    `json:"delete_ips"`
-	Hosts       *[]L2HostReq   `json:"hosts"`
-	LocationId  int            `json:"location_id"`
-	Name        string         `json:"name"`
-	Type        int            `json:"type"`
-}
-
-type L2HostReq struct {
-	Id     int           `json:"id"`
-	Mode   string        `json:"mode"`
-}
-
-func CreateL2(url, email, token, name string, l2Type int, hostNames []HostnameWithType) (*L2Resp, error) {
-	return CreateUpdateL2(url, fmt.Sprintf("%s/rest/l2_segments/", url), "POST",
-		email, token, name, l2Type, hostNames)
-}
-
-func UpdateL2(url, email, token, name, id string, l2Type int, hostNames []HostnameWithType) (*L2Resp, error) {
-	return CreateUpdateL2(url, fmt.Sprintf("%s/rest/l2_segments/%s", url, id), "PUT",
-		email, token, name, l2Type, hostNames)
-}
-
-func CreateUpdateL2(url, fullUrl, method, email, token, name string, l2Type int, hostNames []HostnameWithType) (*L2Resp, error) {
-	l2req, err := GetL2ReqData(url, email, token, name, l2Type, hostNames)
-	if err != nil {
-		return nil, err
-	}
-	data, err := json.Marshal(l2req)
-	if err != nil {
-		return nil, err
-	}
-	body, err := GetResponse(fullUrl, email, token, method, strings.NewReader(string(data)))
-	if err != nil {
-		return nil, err
-	}
-	var l2Data L2DataResp
-	err = json.Unmarshal([]byte(string(*body)), &l2Data)
-	if err != nil {
-		return nil, err
-	}
-	return l2Data.Data, nil
-}
-
 type HostnameWithType struct {
-	Name     string
-	Mode     string
+	Name string
+	Mode string
 }
 
-func GetL2ReqData(url, email, token, name string, l2type int, hostnames []HostnameWithType) (*L2Req, error) {
-	data, err := GetServers(url, email, token)
+func GetL2ReqData(hosts serverscom.HostsService, name string, l2type int, hostnames []HostnameWithType) (*serverscom.L2Request, error) {
+	data, err := hosts.List()
 	if err != nil {
-		return nil ,err
+		return nil, err
 	}
 	locations := []int{}
-	hosts := []L2HostReq{}
+	l2Hosts := []serverscom.L2HostRequest{}
 	for _, hostname := range hostnames {
 		for _, server := range data {
 			if server.Title == hostname.Name {
@@ -115,51 +30,20 @@ func GetL2ReqData(url, email, token, name string, l2type int, hostnames []Hostna
 				} else if len(locations) == 0 {
 					locations = append(locations, server.Location.Id)
 				}
-				hosts = append(hosts, L2HostReq{Id: server.Id, Mode: hostname.Mode})
+				l2Hosts = append(l2Hosts, serverscom.L2HostRequest{Id: server.Id, Mode: hostname.Mode})
 			}
 		}
 	}
 	if len(locations) > 1 {
 		return nil, errors.New("Hosts have different locations.")
 	}
-	if len(hosts) != len(hostnames) {
+	if len(l2Hosts) != len(hostnames) {
 		return nil, errors.New("Not all hosts are ready.")
 	}
-	out := &L2Req { Hosts: &hosts, LocationId: locations[0], Name: name, Type: l2type }
+	out := &serverscom.L2Request{Hosts: l2Hosts, LocationId: locations[0], Name: name, Type: l2type}
 	return out, nil
 }
 
-func GetL2(url, email, token, id string) (*L2Resp, error) {
-	l2list, err := ListL2(url, email, token)
-	if err != nil {
-		return nil, err
-	}
-	for _, l2 := range *l2list {
-		if fmt.Sprintf("%d", l2.Id) == id {
-			return &l2, nil
-		}
-	}
-	return nil, nil
-}
-
-type Success struct {
-	Success bool `json:"success"`
-}
-
-func DeleteL2(url, email, token, id string) (bool, error) {
-	body, err := GetResponse(fmt.Sprintf("%s/rest/l2_segments/%s/", url, id),
-		email, token, "DELETE", nil)
-	if err != nil {
-		return false, err
-	}
-	var success Success
-	err = json.Unmarshal([]byte(string(*body)), &success)
-	if err != nil {
-		return false, nil
-	}
-	return success.Success, nil
-}
-
 type hostsData []HostnameWithType
 
 func retrieveHostNames(list interface{}) ([]string, []HostnameWithType, error) {
@@ -184,11 +68,9 @@ func retrieveHostNames(list interface{}) ([]string, []HostnameWithType, error) {
 }
 
 func resourceL2Create(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
+	client := m.(*serverscom.Client)
 	name := d.Get("name").(string)
-	hostNames, hostNamesWithType, err := retrieveHostNames(d.Get("hostnames"))
+	_, hostNamesWithType, err := retrieveHostNames(d.Get("hostnames"))
 	if err != nil {
 		return err
 	}
@@ -196,53 +78,105 @@ func resourceL2Create(d *schema.ResourceData, m interface{}) error {
 	if err != nil {
 		return err
 	}
-	r, err := CreateL2(url, email, token, name, l2Type, hostNamesWithType)
+	req, err := GetL2ReqData(client.Hosts, name, l2Type, hostNamesWithType)
 	if err != nil {
 		return err
 	}
+	r, err := client.L2Segments.Create(req)
+	if err != nil {
+		return describeAPIError(fmt.Sprintf("Creating L2 segment %s", name), err)
+	}
 	d.SetId(fmt.Sprintf("%d", r.Id))
-	d.Set("name", name)
-	d.Set("hostnames", strings.Join(hostNames[:], ","))
 	return resourceL2Read(d, m)
 }
 
 func resourceL2Read(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
+	client := m.(*serverscom.Client)
 	id := d.Id()
-	l2, err := GetL2(url, email, token, id)
+	l2, err := client.L2Segments.Get(id)
 	if err != nil {
 		return err
 	}
 	if l2 == nil {
+		log.Printf("[WARN] L2 segment %s not found, removing from state", id)
 		d.SetId("")
-		d.Set("name", "")
-		d.Set("hostnames", "")
-		return errors.New(fmt.Sprintf("L2 not found."))
+		return nil
 	} else {
-		d.Set("name", d.Get("name").(string))
-		hostNames, _, err := retrieveHostNames(d.Get("hostnames"))
+		d.Set("name", l2.Name)
+		d.Set("type", getTypeName(l2.Type))
+		return d.Set("hostnames", flattenL2Hosts(l2.Hosts, d.Get("hostnames")))
+	}
+}
+
+// flattenL2Hosts lists the segment's hosts in the order they were declared
+// in, so that the API returning them in another order is not a diff. Hosts
+// not in the declaration, such as after an import, go last.
+func flattenL2Hosts(hosts []serverscom.L2Host, declared interface{}) []map[string]interface{} {
+	position := map[string]int{}
+	if list, ok := declared.([]interface{}); ok {
+		for i, v := range list {
+			if p, ok := v.(map[string]interface{}); ok {
+				position[p["name"].(string)] = i
+			}
+		}
+	}
+	sorted := append([]serverscom.L2Host(nil), hosts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		pi, iok := position[sorted[i].Title]
+		pj, jok := position[sorted[j].Title]
+		if iok && jok {
+			return pi < pj
+		}
+		return iok && !jok
+	})
+	out := make([]map[string]interface{}, 0, len(sorted))
+	for _, h := range sorted {
+		out = append(out, map[string]interface{}{"name": h.Title, "mode": h.Mode})
+	}
+	return out
+}
+
+// resourceL2Import accepts a segment's numeric ID or its name.
+func resourceL2Import(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*serverscom.Client)
+	l2, err := client.L2Segments.Get(d.Id())
+	if err != nil {
+		return nil, err
+	}
+	if l2 == nil {
+		segments, err := client.L2Segments.List()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		d.Set("hostnames", strings.Join(hostNames, ","))
-		return nil
+		for i := range segments {
+			if segments[i].Name != d.Id() {
+				continue
+			}
+			if l2 != nil {
+				return nil, errors.New(fmt.Sprintf("More than one L2 segment is named %s, import it by ID.", d.Id()))
+			}
+			l2 = &segments[i]
+		}
+	}
+	if l2 == nil {
+		return nil, errors.New(fmt.Sprintf("No L2 segment with ID or name %s.", d.Id()))
 	}
+	d.SetId(fmt.Sprintf("%d", l2.Id))
+	return []*schema.ResourceData{d}, nil
 }
 
 func resourceL2Delete(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
+	client := m.(*serverscom.Client)
 	id := d.Id()
-	l2, err := GetL2(url, email, token, id)
+	l2, err := client.L2Segments.Get(id)
 	if err != nil {
 		return err
 	}
 	if l2 != nil && l2.Status == "active" {
-		_, err := DeleteL2(url, email, token, id)
-		if err != nil {
+		_, err := client.L2Segments.Delete(id)
+		if serverscom.IsNotFound(err) {
+			log.Printf("[WARN] L2 segment %s is already gone", id)
+		} else if err != nil {
 			return err
 		}
 		d.SetId("")
@@ -251,9 +185,17 @@ func resourceL2Delete(d *schema.ResourceData, m interface{}) error {
 	} else if l2 != nil && l2.Status != "active" {
 		return errors.New(fmt.Sprintf("Cannot delete %s segment, because of it's status.", l2.Name))
 	} else if l2 == nil {
-		return errors.New(fmt.Sprintf("Cannot delete segment with id: %s.", id))
+		log.Printf("[WARN] L2 segment %s is already gone", id)
+		d.SetId("")
 	}
-	return resourceL2Read(d, m)
+	return nil
+}
+
+func getTypeName(l2Type int) string {
+	if l2Type == 1 {
+		return "public"
+	}
+	return "private"
 }
 
 func getType(typeName string) (int, error) {
@@ -269,31 +211,30 @@ func getType(typeName string) (int, error) {
 func resourceL2Update(d *schema.ResourceData, m interface{}) error {
 	d.Partial(true)
 	if d.HasChange("name") || d.HasChange("hostnames") {
-		url := m.(*Client).Url
-		email := m.(*Client).Email
-		token := m.(*Client).Token
+		client := m.(*serverscom.Client)
 		id := d.Id()
 		l2Type, err := getType(d.Get("type").(string))
 		if err != nil {
 			return err
 		}
-		l2, err := GetL2(url, email, token, id)
+		l2, err := client.L2Segments.Get(id)
 		if err != nil {
 			return err
 		}
 		if l2 != nil && l2.Status == "active" {
-			url := m.(*Client).Url
-			email := m.(*Client).Email
-			token := m.(*Client).Token
 			name := d.Get("name").(string)
 			_, hostNamesWithType, err := retrieveHostNames(d.Get("hostnames"))
 			if err != nil {
 				return err
 			}
-			_, err = UpdateL2(url, email, token, name, d.Id(), l2Type, hostNamesWithType)
+			req, err := GetL2ReqData(client.Hosts, name, l2Type, hostNamesWithType)
 			if err != nil {
 				return err
 			}
+			_, err = client.L2Segments.Update(id, req)
+			if err != nil {
+				return describeAPIError(fmt.Sprintf("Updating L2 segment %s", name), err)
+			}
 		} else if l2 != nil && l2.Status != "active" {
 			return errors.New(fmt.Sprintf("Cannot update %s segment, because of it's status.", l2.Name))
 		} else if l2 == nil {
@@ -312,11 +253,14 @@ func resourceL2() *schema.Resource {
 		Read:   resourceL2Read,
 		Delete: resourceL2Delete,
 		Update: resourceL2Update,
+		Importer: &schema.ResourceImporter{
+			State: resourceL2Import,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
 				ValidateFunc: validation.NoZeroValues,
 			},
 			"hostnames": &schema.Schema{
@@ -326,14 +270,14 @@ func resourceL2() *schema.Resource {
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"name": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
 							ValidateFunc: validation.NoZeroValues,
 						},
 						"mode": &schema.Schema{
-							Type:     schema.TypeString,
-							Optional: true,
-							Default: "native",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "native",
 							ValidateFunc: validation.NoZeroValues,
 						},
 					},
@@ -342,7 +286,7 @@ func resourceL2() *schema.Resource {
 			"type": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
-				Default: "public",
+				Default:  "public",
 			},
 		},
 	}
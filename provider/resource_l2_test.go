@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"regexp"
+	"servers.com/terraform-provider/serverscom/serverscomtest"
+	"testing"
+)
+
+func TestAccL2_basic(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	fake.AddHost("l2-a", 1)
+	fake.AddHost("l2-b", 1)
+	fake.AddHost("l2-c", 1)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckL2Destroy(t, fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccL2Config("backend", "l2-a", "l2-b"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("serverscom_l2.test", "name", "backend"),
+					resource.TestCheckResourceAttr("serverscom_l2.test", "type", "public"),
+					resource.TestCheckResourceAttr("serverscom_l2.test", "hostnames.#", "2"),
+					resource.TestCheckResourceAttr("serverscom_l2.test", "hostnames.0.name", "l2-a"),
+					resource.TestCheckResourceAttr("serverscom_l2.test", "hostnames.1.name", "l2-b"),
+				),
+			},
+			{
+				Config: testAccL2Config("storage", "l2-c", "l2-a"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("serverscom_l2.test", "name", "storage"),
+					resource.TestCheckResourceAttr("serverscom_l2.test", "hostnames.0.name", "l2-c"),
+					resource.TestCheckResourceAttr("serverscom_l2.test", "hostnames.1.name", "l2-a"),
+				),
+			},
+			{
+				ResourceName:      "serverscom_l2.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccL2_differentLocations(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	fake.AddHost("ams-1", 1)
+	fake.AddHost("dal-1", 2)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccL2Config("split", "ams-1", "dal-1"),
+				ExpectError: regexp.MustCompile("Hosts have different locations"),
+			},
+		},
+	})
+}
+
+func testAccCheckL2Destroy(t *testing.T, fake *serverscomtest.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		segments, err := testAccClient(t, fake).L2Segments.List()
+		if err != nil {
+			return err
+		}
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "serverscom_l2" {
+				continue
+			}
+			for _, seg := range segments {
+				if fmt.Sprintf("%d", seg.Id) == rs.Primary.ID {
+					return errors.New(fmt.Sprintf("L2 segment %s still exists.", seg.Name))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func testAccL2Config(name, first, second string) string {
+	return fmt.Sprintf(`
+resource "serverscom_l2" "test" {
+  name = %q
+
+  hostnames {
+    name = %q
+  }
+  hostnames {
+    name = %q
+  }
+}
+`, name, first, second)
+}
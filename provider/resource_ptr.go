@@ -1,84 +1,16 @@
 package provider
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
-	"strings"
+	"log"
+	"servers.com/terraform-provider/serverscom"
 )
 
-type PtrData struct {
-	Data Ptr `json:"data"`
-}
-
-type PtrDataList struct {
-	Data []Ptr `json:"data"`
-}
-
-type Ptr struct {
-	Id           int         `json:"id"`
-	DomainId     int         `json:"domain_id"`
-	Type         string      `json:"type"`
-	Name         string      `json:"name"`
-	Ttl          int         `json:"ttl"`
-	Priority     int         `json:"priority"`
-	Data         interface{} `json:"data"`
-	Disabled     bool        `json:"disabled"`
-}
-
-func AddPtr(url, email, token, data, name string) (*Ptr, error) {
-	body, err := GetResponse(fmt.Sprintf("%s/rest/dns/records//", url),
-		email, token, "POST",
-		strings.NewReader(fmt.Sprintf(`{"data":"%s","name":"%s"}`, data, name)))
-	if err != nil {
-		return nil, err
-	}
-	var ptrData PtrData
-	err = json.Unmarshal([]byte(string(*body)), &ptrData)
-	if err != nil {
-		return nil, err
-	}
-	return &ptrData.Data, nil
-}
-
-func ListPtr(url, email, token string) (*[]Ptr, error) {
-	body, err := GetResponse(fmt.Sprintf("%s/rest/dns/records//", url),
-		email, token, "GET", nil)
-	if err != nil {
-		return nil, err
-	}
-	var ptrData PtrDataList
-	err = json.Unmarshal([]byte(string(*body)), &ptrData)
-	if err != nil {
-		return nil, err
-	}
-	return &ptrData.Data, nil
-}
-
-func GetPtr(url, email, token, id string) (*Ptr, error) {
-	ptrs, err := ListPtr(url, email, token)
-	if err != nil {
-		return nil, err
-	}
-	for _, ptr := range *ptrs {
-		if fmt.Sprintf("%d", ptr.Id) == id {
-			return &ptr, nil
-		}
-	}
-	return nil, nil
-}
-
-func DeletePtr(url, email, token, ptrId, domainId string) error {
-	_, err := GetResponse(fmt.Sprintf(`%s/rest/dns/records///%s`, url, ptrId),
-		email, token, "DELETE",
-		strings.NewReader(fmt.Sprintf(`{"domain_id":%s}`, domainId)))
-	return err
-}
-
-func GetHostNetwork(url, email, token, hostname string) (*Network, error) {
-	s, err := GetServer(url, email, token, hostname)
+func GetHostNetwork(hosts serverscom.HostsService, hostname string) (*serverscom.Network, error) {
+	s, err := hosts.GetByTitle(hostname)
 	if err != nil {
 		return nil, err
 	}
@@ -94,90 +26,144 @@ func GetHostNetwork(url, email, token, hostname string) (*Network, error) {
 }
 
 func resourcePtrCreate(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
+	client := m.(*serverscom.Client)
 	hostname := d.Get("hostname").(string)
 	ptrAddress := d.Get("ptr").(string)
-	network, err := GetHostNetwork(url, email, token, hostname)
+	network, err := GetHostNetwork(client.Hosts, hostname)
 	if err != nil {
 		return err
 	}
-	ptr, err := AddPtr(url, email, token, ptrAddress, network.HostIp)
+	ptr, err := client.DNS.CreatePtr(ptrAddress, network.HostIp)
 	if err != nil {
-		return err
+		return describeAPIError(fmt.Sprintf("Creating PTR %s for %s", ptrAddress, network.HostIp), err)
 	}
 	d.SetId(fmt.Sprintf("%d", ptr.Id))
-	d.Set("hostname", hostname)
-	d.Set("ptr", ptrAddress)
-	d.Set("domainId", fmt.Sprintf("%d", ptr.DomainId))
 	return resourcePtrRead(d, m)
 }
 
 func resourcePtrRead(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
-	ptr, err := GetPtr(url, email, token, d.Id())
+	client := m.(*serverscom.Client)
+	ptr, err := client.DNS.GetRecord(d.Id())
 	if err != nil {
 		return err
 	}
 	if ptr == nil {
+		log.Printf("[WARN] Ptr %s not found, removing from state", d.Id())
 		d.SetId("")
-		d.Set("ptr", "")
-		d.Set("domainId", "")
-		d.Set("hostname", "")
-		return errors.New(fmt.Sprintf("Ptr not found."))
+		return nil
 	} else {
-		d.Set("ptr", d.Get("ptr").(string))
-		d.Set("domainId", fmt.Sprintf("%d", ptr.DomainId))
-		d.Set("hostname", d.Get("hostname").(string))
+		d.Set("ptr", fmt.Sprintf("%v", ptr.Data))
+		d.Set("ip", ptr.Name)
+		d.Set("domain_id", ptr.DomainId)
+		host, err := findHostByIp(client.Hosts, ptr.Name)
+		if err != nil {
+			return err
+		}
+		if host != nil {
+			d.Set("hostname", host.Title)
+		}
 		return nil
 	}
 }
 
+// findHostByIp returns the active host that has ip on one of its networks.
+func findHostByIp(hosts serverscom.HostsService, ip string) (*serverscom.Host, error) {
+	list, err := hosts.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		for _, n := range list[i].Networks {
+			if n.HostIp == ip {
+				return &list[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// resourcePtrImport accepts a record's numeric ID or the IP address it
+// belongs to.
+func resourcePtrImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*serverscom.Client)
+	ptr, err := client.DNS.GetRecord(d.Id())
+	if err != nil {
+		return nil, err
+	}
+	if ptr == nil {
+		records, err := client.DNS.ListRecords()
+		if err != nil {
+			return nil, err
+		}
+		for i := range records {
+			if records[i].Name != d.Id() {
+				continue
+			}
+			if ptr != nil {
+				return nil, errors.New(fmt.Sprintf("More than one PTR record exists for %s, import it by ID.", d.Id()))
+			}
+			ptr = &records[i]
+		}
+	}
+	if ptr == nil {
+		return nil, errors.New(fmt.Sprintf("No PTR record with ID or IP %s.", d.Id()))
+	}
+	d.SetId(fmt.Sprintf("%d", ptr.Id))
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourcePtrDelete(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
-	ptr, err := GetPtr(url, email, token, d.Id())
+	client := m.(*serverscom.Client)
+	ptr, err := client.DNS.GetRecord(d.Id())
 	if err != nil {
 		return err
 	}
-	if err = DeletePtr(url, email, token, d.Id(), fmt.Sprintf("%d", ptr.DomainId)); err != nil{
+	if ptr == nil {
+		log.Printf("[WARN] Ptr %s is already gone", d.Id())
+		d.SetId("")
+		return nil
+	}
+	err = client.DNS.DeleteRecord(d.Id(), ptr.DomainId)
+	if serverscom.IsNotFound(err) {
+		log.Printf("[WARN] Ptr %s is already gone", d.Id())
+	} else if err != nil {
 		return err
 	}
 	d.SetId("")
-	return resourcePtrRead(d, m)
+	return nil
 }
 
 func resourcePtrUpdate(d *schema.ResourceData, m interface{}) error {
 	d.Partial(true)
 	if d.HasChange("hostname") || d.HasChange("ptr") {
-		url := m.(*Client).Url
-		email := m.(*Client).Email
-		token := m.(*Client).Token
-		ptr, err := GetPtr(url, email, token, d.Id())
+		client := m.(*serverscom.Client)
+		ptr, err := client.DNS.GetRecord(d.Id())
 		if err != nil {
 			return err
 		}
-		if err = DeletePtr(url, email, token, d.Id(), fmt.Sprintf("%d", ptr.DomainId)); err != nil {
-			return err
+		if ptr == nil {
+			log.Printf("[WARN] Ptr %s is already gone", d.Id())
+		} else {
+			err = client.DNS.DeleteRecord(d.Id(), ptr.DomainId)
+			if serverscom.IsNotFound(err) {
+				log.Printf("[WARN] Ptr %s is already gone", d.Id())
+			} else if err != nil {
+				return err
+			}
 		}
 		hostname := d.Get("hostname").(string)
 		ptrAddress := d.Get("ptr").(string)
-		network, err := GetHostNetwork(url, email, token, hostname)
+		network, err := GetHostNetwork(client.Hosts, hostname)
 		if err != nil {
 			return err
 		}
-		ptr, err = AddPtr(url, email, token, ptrAddress, network.HostIp)
+		ptr, err = client.DNS.CreatePtr(ptrAddress, network.HostIp)
 		if err != nil {
-			return err
+			return describeAPIError(fmt.Sprintf("Creating PTR %s for %s", ptrAddress, network.HostIp), err)
 		}
 		d.SetId(fmt.Sprintf("%d", ptr.Id))
 		d.SetPartial("hostname")
 		d.SetPartial("ptr")
-		d.Set("domainId", fmt.Sprintf("%d", ptr.DomainId))
 	}
 	d.Partial(false)
 
@@ -190,18 +176,29 @@ func resourcePtr() *schema.Resource {
 		Read:   resourcePtrRead,
 		Delete: resourcePtrDelete,
 		Update: resourcePtrUpdate,
+		Importer: &schema.ResourceImporter{
+			State: resourcePtrImport,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"hostname": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
 				ValidateFunc: validation.NoZeroValues,
 			},
 			"ptr": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
 				ValidateFunc: validation.NoZeroValues,
 			},
+			"ip": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"domain_id": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
 		},
 	}
-}
\ No newline at end of file
+}
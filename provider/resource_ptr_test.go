@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"servers.com/terraform-provider/serverscom/serverscomtest"
+	"testing"
+)
+
+func TestAccPtr_basic(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	web := fake.AddHost("web-1", 1)
+	fake.AddHost("web-2", 1)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckPtrDestroy(t, fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPtrConfig("web-1", "web-1.example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("serverscom_ptr.test", "hostname", "web-1"),
+					resource.TestCheckResourceAttr("serverscom_ptr.test", "ptr", "web-1.example.com"),
+					resource.TestCheckResourceAttr("serverscom_ptr.test", "ip", web.Networks[0].HostIp),
+					resource.TestCheckResourceAttrSet("serverscom_ptr.test", "domain_id"),
+				),
+			},
+			{
+				Config: testAccPtrConfig("web-2", "web-2.example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("serverscom_ptr.test", "hostname", "web-2"),
+					resource.TestCheckResourceAttr("serverscom_ptr.test", "ptr", "web-2.example.com"),
+				),
+			},
+			{
+				ResourceName:      "serverscom_ptr.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccPtr_deletedOutsideTerraform(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	fake.AddHost("mail-1", 1)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckPtrDestroy(t, fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPtrConfig("mail-1", "mail.example.com"),
+			},
+			{
+				// The missing record is dropped from state and planned again.
+				PreConfig: func() {
+					client := testAccClient(t, fake)
+					records, err := client.DNS.ListRecords()
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, rec := range records {
+						if err := client.DNS.DeleteRecord(fmt.Sprintf("%d", rec.Id), rec.DomainId); err != nil {
+							t.Fatal(err)
+						}
+					}
+				},
+				Config:             testAccPtrConfig("mail-1", "mail.example.com"),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccCheckPtrDestroy(t *testing.T, fake *serverscomtest.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		records, err := testAccClient(t, fake).DNS.ListRecords()
+		if err != nil {
+			return err
+		}
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "serverscom_ptr" {
+				continue
+			}
+			for _, rec := range records {
+				if fmt.Sprintf("%d", rec.Id) == rs.Primary.ID {
+					return errors.New(fmt.Sprintf("PTR %v still exists.", rec.Data))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func testAccPtrConfig(hostname, ptr string) string {
+	return fmt.Sprintf(`
+resource "serverscom_ptr" "test" {
+  hostname = %q
+  ptr      = %q
+}
+`, hostname, ptr)
+}
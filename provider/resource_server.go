@@ -1,334 +1,871 @@
 package provider
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
+	"log"
+	"servers.com/terraform-provider/serverscom"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-type HostsList struct {
-	Data []Host `json:"data"`
-}
-
-type Network struct {
-	Id				   int    `json:"id"`
-	HostIp             string `json:"host_ip"`
-	PoolType           string `json:"pool_type"`
-	Size               int    `json:"size"`
-	Netmask            string `json:"netmask"`
-}
-
-type Location struct {
-	Id				   int        `json:"id"`
-	Name               string     `json:"name"`
+func GetPendingServer(hosts serverscom.HostsService, hostname string) (*serverscom.Host, error) {
+	pending, err := hosts.ListPending()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range pending {
+		if h.Title == hostname {
+			return &h, nil
+		}
+	}
+	return nil, nil
 }
 
-type Host struct {
-	Id                 int         `json:"id"`
-	Type               int         `json:"type"`
-	Title              string      `json:"title"`
-	Conf               string      `json:"conf"`
-	ServiceType        int         `json:"service_type"`
-	LeaseEnd           interface{} `json:"lease_end"`
-	Networks           []Network   `json:"networks"`
-	Location           Location    `json:"location"`
-	ProjectId          interface{} `json:"project_id"`
-	ProjectName        interface{} `json:"project_name"`
-	ScheduledReleaseAt interface{} `json:"scheduled_release_at"`
-	RackName           interface{} `json:"rack_name"`
-	RackId             interface{} `json:"rack_id"`
-	L2Segments         interface{} `json:"l2_segments"`
-}
+// IsServerOrOrderExists reports whether hostname is an active or pending
+// host, or is still on its way through the order with id orderID. States
+// written before order IDs were recorded have orderID 0; for those the
+// hostname is looked up in the order descriptions instead.
+func IsServerOrOrderExists(client *serverscom.Client, hostname string, orderID int) (bool, error) {
+	s, err := client.Hosts.GetByTitle(hostname)
+	if err != nil {
+		return false, err
+	}
+	if s != nil {
+		return true, nil
+	}
 
-type OrdersList struct {
-	Data []Order `json:"data"`
-}
+	s, err = GetPendingServer(client.Hosts, hostname)
+	if err != nil {
+		return false, err
+	}
+	if s != nil {
+		return true, nil
+	}
 
-type Order struct {
-	Amount             float64  `json:"amount"`
-	AmountTax          float64  `json:"amount_tax"`
-	AmountTotal        float64  `json:"amount_total"`
-	CreatedTime        string   `json:"created_time"`
-	Currency           string   `json:"currency"`
-	Description        []string `json:"description"`
-	Id                 int      `json:"id"`
-	OriginalAmount     float64  `json:"original_amount"`
-	OriginalCurrency   string   `json:"original_currency"`
-	Status             int      `json:"status"`
+	if orderID != 0 {
+		order, err := client.Orders.Get(orderID)
+		if err != nil {
+			return false, err
+		}
+		return order != nil && order.IsOpen(), nil
+	}
+	order, err := findOpenOrder(client.Orders, hostname)
+	if err != nil {
+		return false, err
+	}
+	return order != nil, nil
 }
 
-func AddToCart(url, email, token, hostname, config string) error {
-	url = fmt.Sprintf(`%s/rest/server_cart_items`, url)
-	data := strings.NewReader(fmt.Sprintf(config, hostname))
-	_, err := GetResponse(url, email, token, "POST", data)
-	return err
+// findOpenOrder returns the newest order for hostname that is not completed.
+func findOpenOrder(orders serverscom.OrdersService, hostname string) (*serverscom.Order, error) {
+	list, err := orders.List()
+	if err != nil {
+		return nil, err
+	}
+	var found *serverscom.Order
+	for i, order := range list {
+		if !order.IsOpen() {
+			continue
+		}
+		for _, h := range order.Description {
+			if h == hostname && (found == nil || order.Id > found.Id) {
+				found = &list[i]
+			}
+		}
+	}
+	return found, nil
 }
 
-func CheckoutOrder(url, email, token string) error {
-	data := strings.NewReader("{\"ts\":1456817777230}")
-	_, err := GetResponse(fmt.Sprintf("%s/rest/orders", url),
-		email, token, "POST", data)
-	return err
-}
+// cartMu serializes cart use within the provider process. The cart belongs
+// to the account, so two creates running in parallel would otherwise check
+// out each other's items.
+var cartMu sync.Mutex
 
-func CancelServer(url string, serverid int, email, pwd, token string) error {
-	data := strings.NewReader(fmt.Sprintf("{\"token\":\"%s\"}", pwd))
-	_, err := GetResponse(fmt.Sprintf("%s/rest/hosts/%d/schedule_release", url, serverid),
-		email, token, "POST", data)
-	return err
-}
+// orderServer buys exactly one server and returns its order. It refuses to
+// touch a cart that already holds items, since checkout would buy those too,
+// and takes its own item back out if anything goes wrong before checkout.
+func orderServer(orders serverscom.OrdersService, hostname, payload string) (*serverscom.Order, error) {
+	cartMu.Lock()
+	defer cartMu.Unlock()
 
-func GetServers(url, email, token string) ([]Host, error) {
-	body, err := GetResponse(fmt.Sprintf(`%s/rest/hosts`, url), email, token, "GET", nil)
+	if err := checkCartEmpty(orders, 0); err != nil {
+		return nil, err
+	}
+	item, err := orders.AddToCart(payload)
+	if err != nil {
+		return nil, describeAPIError(fmt.Sprintf("Adding %s to the cart", hostname), err)
+	}
+	if err = checkCartEmpty(orders, item.Id); err != nil {
+		removeFromCart(orders, item.Id)
+		return nil, err
+	}
+	order, err := orders.Checkout()
+	if err != nil {
+		removeFromCart(orders, item.Id)
+		return nil, describeAPIError(fmt.Sprintf("Checking out the order for %s", hostname), err)
+	}
+	if order.Id != 0 {
+		return order, nil
+	}
+	// The server was bought, so failing here would lose track of it.
+	log.Printf("[WARN] Checkout for %s returned no order ID, looking the order up by hostname", hostname)
+	order, err = findOpenOrder(orders, hostname)
 	if err != nil {
 		return nil, err
 	}
-	var hosts HostsList
-	errUnmarshal := json.Unmarshal([]byte(string(*body)), &hosts)
-	if errUnmarshal != nil {
-		return []Host{}, errUnmarshal
+	if order == nil {
+		return nil, errors.New(fmt.Sprintf("Order for %s was placed, but cannot be found in the order list.", hostname))
 	}
-	return hosts.Data, err
+	return order, nil
 }
 
-func GetPendingServers(url, email, token string) ([]Host, error) {
-	body, err := GetResponse(fmt.Sprintf(`%s/rest/hosts_pending`, url), email, token, "GET", nil)
+// checkCartEmpty fails if the cart holds anything besides the item with id
+// own (0 for none).
+func checkCartEmpty(orders serverscom.OrdersService, own int) error {
+	items, err := orders.ListCart()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	var foreign []string
+	for _, item := range items {
+		if item.Id != own {
+			foreign = append(foreign, fmt.Sprintf("%d", item.Id))
+		}
+	}
+	if len(foreign) > 0 {
+		return errors.New(fmt.Sprintf("The account's cart contains items not added by Terraform (ids: %s). "+
+			"Checking out would buy them too; empty the cart in the portal and retry.", strings.Join(foreign, ", ")))
 	}
-	var hosts HostsList
-	errUnmarshal := json.Unmarshal([]byte(string(*body)), &hosts)
-	if errUnmarshal != nil {
-		return nil, errUnmarshal
+	return nil
+}
+
+func removeFromCart(orders serverscom.OrdersService, id int) {
+	if err := orders.RemoveFromCart(id); err != nil {
+		log.Printf("[WARN] Could not remove cart item %d: %s", id, err)
 	}
-	return hosts.Data, err
 }
 
-func GetOrders(url, email, token string) ([]Order, error) {
-	body, err := GetResponse(fmt.Sprintf(`%s/rest/orders`, url), email, token, "GET", nil)
+func resourceServerCreate(d *schema.ResourceData, m interface{}) error {
+	client := m.(*serverscom.Client)
+	hostname := d.Get("hostname").(string)
+	id, orderID, err := resumeServer(client, hostname)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if id == "" {
+		payload, err := cartPayload(d, hostname)
+		if err != nil {
+			return err
+		}
+		order, err := orderServer(client.Orders, hostname, payload)
+		if err != nil {
+			return err
+		}
+		id, orderID = orderPlaceholderId(order.Id), order.Id
 	}
-	var orders OrdersList
-	errUnmarshal := json.Unmarshal([]byte(string(*body)), &orders)
-	if errUnmarshal != nil {
-		return nil, errUnmarshal
+	// Record the order before anything else can fail, so that later runs
+	// follow this order instead of placing a second one.
+	d.SetId(id)
+	d.Set("hostname", hostname)
+	d.Set("order_id", orderID)
+	var waitErr error
+	if d.Get("wait_for_ready").(bool) {
+		waitErr = waitForServer(client, hostname, orderID, d.Timeout(schema.TimeoutCreate))
+	}
+	if err = resourceServerRead(d, m); err != nil {
+		// Failing now would taint the server that was just bought, so leave
+		// it to the next refresh.
+		log.Printf("[WARN] Reading server %s after ordering it: %s", hostname, err)
 	}
-	for _, order := range orders.Data {
-		fmt.Println(fmt.Sprintf("host: %d", order.Id))
+	if waitErr != nil {
+		// The error taints the resource, and replacing it would cancel the
+		// order and buy another server, so say how to keep it instead.
+		return errors.New(fmt.Sprintf("%s. The order is kept in the state; run terraform untaint on the resource to keep following it instead of replacing it.", waitErr))
 	}
-	return orders.Data, nil
+	return nil
 }
 
-func GetServer(url, email, token, hostname string) (*Host, error) {
-	body, err := GetResponse(fmt.Sprintf(`%s/rest/hosts?title=%s`, url, hostname), email, token, "GET", nil)
+// resumeServer finds what an earlier, interrupted run already ordered for
+// hostname: its open order, or else its pending host. It returns the
+// resource ID and order ID to carry on with, or an empty ID when there is
+// nothing to resume.
+func resumeServer(client *serverscom.Client, hostname string) (string, int, error) {
+	host, err := client.Hosts.GetByTitle(hostname)
 	if err != nil {
-		return nil, err
+		return "", 0, err
 	}
-	var hosts HostsList
-	errUnmarshal := json.Unmarshal([]byte(string(*body)), &hosts)
-	if errUnmarshal != nil {
-		hosts = HostsList{}
-		return nil, errUnmarshal
-	}
-	if len(hosts.Data) > 1 {
-		hosts = HostsList{}
-		return nil, errors.New(fmt.Sprintf("Hostname: %s is not unique.", hostname))
-	} else if len(hosts.Data) == 1 {
-		return &hosts.Data[0], err
-	} else {
-		return nil, err
+	if host != nil {
+		return "", 0, errors.New(fmt.Sprintf("Server %s already exists as host %d; import it instead of ordering it again.", hostname, host.Id))
+	}
+	order, err := findOpenOrder(client.Orders, hostname)
+	if err != nil {
+		return "", 0, err
+	}
+	if order != nil {
+		log.Printf("[INFO] Resuming open order %d for server %s", order.Id, hostname)
+		return orderPlaceholderId(order.Id), order.Id, nil
 	}
+	pending, err := GetPendingServer(client.Hosts, hostname)
+	if err != nil {
+		return "", 0, err
+	}
+	if pending != nil {
+		log.Printf("[INFO] Resuming pending server %s (ID %d)", hostname, pending.Id)
+		return pendingPlaceholderId(pending.Id), 0, nil
+	}
+	return "", 0, nil
 }
 
-func GetPendingServer(url, email, token, hostname string) (*Host, error) {
-	hosts, err := GetPendingServers(url, email, token)
+// serverPollInterval is how long waits for orders and servers pause between
+// API calls.
+var serverPollInterval = 10 * time.Second
+
+// waitForServer polls until the server ordered with orderID shows up as an
+// active host, logging each stage it passes through.
+func waitForServer(client *serverscom.Client, hostname string, orderID int, timeout time.Duration) error {
+	log.Printf("[INFO] Waiting up to %s for server %s (order %d) to become active", timeout, hostname, orderID)
+	conf := &resource.StateChangeConf{
+		Pending:    []string{"ordered", "pending"},
+		Target:     []string{"active"},
+		Refresh:    serverStateRefreshFunc(client, hostname, orderID),
+		Timeout:    timeout,
+		Delay:      serverPollInterval,
+		MinTimeout: serverPollInterval,
+	}
+	_, err := conf.WaitForState()
 	if err != nil {
-		return nil, err
+		return errors.New(fmt.Sprintf("Server %s (order %d) did not become active: %s", hostname, orderID, err))
 	}
-	for _, h := range hosts {
-		if h.Title == hostname {
-			return &h, nil
+	log.Printf("[INFO] Server %s is active", hostname)
+	return nil
+}
+
+func serverStateRefreshFunc(client *serverscom.Client, hostname string, orderID int) resource.StateRefreshFunc {
+	last := ""
+	return func() (interface{}, string, error) {
+		// The lists are cached for the whole run; polling needs them fresh.
+		client.InvalidateCache(serverscom.CacheHosts, serverscom.CachePendingHosts, serverscom.CacheOrders)
+		result, state, err := serverState(client, hostname, orderID)
+		if err == nil && state != last {
+			log.Printf("[INFO] Server %s is %s", hostname, state)
+			last = state
 		}
+		return result, state, err
 	}
-	return nil, nil
 }
 
-func IsServerOrOrderExists(url, email, token, hostname string) (bool, error) {
-	s, err := GetServer(url, email, token, hostname)
+func serverState(client *serverscom.Client, hostname string, orderID int) (interface{}, string, error) {
+	s, err := client.Hosts.GetByTitle(hostname)
 	if err != nil {
-		return false, err
+		return nil, "", err
 	}
 	if s != nil {
-		return true, nil
+		return s, "active", nil
 	}
-
-	s, err = GetPendingServer(url, email, token, hostname)
+	s, err = GetPendingServer(client.Hosts, hostname)
 	if err != nil {
-		return false, err
+		return nil, "", err
 	}
 	if s != nil {
-		return true, nil
+		return s, "pending", nil
 	}
-
-	orders, err := GetOrders(url, email, token)
+	order, err := client.Orders.Get(orderID)
 	if err != nil {
-		return false, err
+		return nil, "", err
 	}
-	for _, order := range orders {
-		if order.Status != 2 {
-			d := order.Description
-			for _, h := range d {
-				if h == hostname {
-					return true, nil
-				}
-			}
+	if order != nil && order.IsOpen() {
+		return order, "ordered", nil
+	}
+	if order != nil && order.Status == serverscom.OrderStatusCancelled {
+		return nil, "", errors.New(fmt.Sprintf("Order %d for server %s was cancelled.", orderID, hostname))
+	}
+	return nil, "", nil
+}
+
+// orderPlaceholderId is the resource ID of a server that is still on order
+// and has no host ID yet. Read replaces it once the host shows up.
+func orderPlaceholderId(orderID int) string {
+	return fmt.Sprintf("order-%d", orderID)
+}
+
+// pendingPlaceholderId is the resource ID of a pending server picked up
+// without its order. Like an order placeholder, Read replaces it.
+func pendingPlaceholderId(hostID int) string {
+	return fmt.Sprintf("pending-%d", hostID)
+}
+
+// upgradeInProgress reports whether the upgrade order in upgrade_order_id
+// is still open, and clears it once it is not.
+func upgradeInProgress(client *serverscom.Client, d *schema.ResourceData) (bool, error) {
+	if orderID := d.Get("upgrade_order_id").(int); orderID != 0 {
+		order, err := client.Orders.Get(orderID)
+		if err != nil {
+			return false, err
+		}
+		if order != nil && order.IsOpen() {
+			log.Printf("[INFO] Upgrade order %d of server %s is still open", orderID, d.Get("hostname").(string))
+			return true, nil
 		}
 	}
+	d.Set("upgrade_order_id", 0)
 	return false, nil
 }
 
-func resourceServerCreate(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
+// lookupServer finds the active host behind d, by host ID when the resource
+// has one and by hostname while it still has a placeholder ID.
+func lookupServer(client *serverscom.Client, d *schema.ResourceData) (*serverscom.Host, error) {
+	if id, err := strconv.Atoi(d.Id()); err == nil {
+		return client.Hosts.Get(id)
+	}
+	return client.Hosts.GetByTitle(d.Get("hostname").(string))
+}
+
+func resourceServerRead(d *schema.ResourceData, m interface{}) error {
+	client := m.(*serverscom.Client)
 	hostname := d.Get("hostname").(string)
-	isExist, err := IsServerOrOrderExists(url, email, token, hostname)
+	// Only plans have actions; after an apply or refresh there are none left,
+	// so the next plan shows its actions even if they are the same again.
+	d.Set("update_actions", []string{})
+	host, err := lookupServer(client, d)
 	if err != nil {
 		return err
 	}
-	if isExist {
-		return errors.New(fmt.Sprintf("Order cannot be created. Hostname: %s is not unique.", hostname))
+	if host != nil {
+		d.SetId(strconv.Itoa(host.Id))
+		if host.ScheduledReleaseAt != nil {
+			log.Printf("[WARN] Server %s is scheduled for release at %v", host.Title, host.ScheduledReleaseAt)
+		}
+		if err = setHostAttributes(d, host); err != nil {
+			return err
+		}
+		// Servers ordered with the deprecated raw config get the structured
+		// attributes too, so that they can switch to them without a diff.
+		config, err := client.Hosts.GetConfiguration(host.Id)
+		if err != nil {
+			return err
+		}
+		upgrading, err := upgradeInProgress(client, d)
+		if err != nil {
+			return err
+		}
+		if !upgrading {
+			return setServerConfig(d, config)
+		}
+		// The host keeps its old hardware until the upgrade order completes.
+		// Keep the upgrade in the state meanwhile, or the next plan would
+		// order it again.
+		planned := make(map[string]interface{}, len(serverUpgradeKeys))
+		for _, key := range serverUpgradeKeys {
+			planned[key] = d.Get(key)
+		}
+		if err = setServerConfig(d, config); err != nil {
+			return err
+		}
+		for _, key := range serverUpgradeKeys {
+			if err = d.Set(key, planned[key]); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	config := d.Get("config").(string)
-	err = AddToCart(url, email, token, hostname, config)
-	if err != nil {
-		return err
+
+	if id, err := strconv.Atoi(d.Id()); err == nil {
+		log.Printf("[WARN] Server %s (ID %d) not found, removing from state", hostname, id)
+		d.SetId("")
+		return nil
 	}
-	err = CheckoutOrder(url, email, token)
+	isExist, err := IsServerOrOrderExists(client, hostname, d.Get("order_id").(int))
 	if err != nil {
 		return err
 	}
-	d.SetId(hostname)
+	if !isExist {
+		log.Printf("[WARN] Server %s not found, removing from state", hostname)
+		d.SetId("")
+		return nil
+	}
 	d.Set("hostname", hostname)
+	// Nothing is known about the host until it is delivered. Empty lists keep
+	// the plan from showing them as unknown in the meantime; declared config
+	// lists keep their configured values.
+	if err = d.Set("networks", []interface{}{}); err != nil {
+		return err
+	}
+	for _, key := range []string{"os", "hdds", "disks", "uplinks"} {
+		if len(d.Get(key).([]interface{})) == 0 {
+			d.Set(key, []interface{}{})
+		}
+	}
 	return nil
-	//return resourceServerRead(d, m)
 }
 
-func resourceServerRead(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
+// setHostAttributes copies what the API knows about an active host into the
+// computed attributes. They stay empty while the server is still on order.
+func setHostAttributes(d *schema.ResourceData, host *serverscom.Host) error {
+	d.Set("hostname", host.Title)
+	d.Set("server_id", host.Id)
+	d.Set("location_name", host.Location.Name)
+	d.Set("rack_name", optionalString(host.RackName))
+	d.Set("project_name", optionalString(host.ProjectName))
+	d.Set("lease_end", optionalString(host.LeaseEnd))
+	d.Set("scheduled_release_at", optionalString(host.ScheduledReleaseAt))
+
+	networks := make([]map[string]interface{}, 0, len(host.Networks))
+	for _, n := range host.Networks {
+		networks = append(networks, map[string]interface{}{
+			"id":        n.Id,
+			"host_ip":   n.HostIp,
+			"pool_type": n.PoolType,
+			"size":      n.Size,
+			"netmask":   n.Netmask,
+		})
+		switch n.PoolType {
+		case "public":
+			d.Set("public_ipv4", n.HostIp)
+			d.Set("public_netmask", n.Netmask)
+		case "private":
+			d.Set("private_ipv4", n.HostIp)
+			d.Set("private_netmask", n.Netmask)
+		}
+	}
+	return d.Set("networks", networks)
+}
+
+// optionalString renders the loosely typed Host fields, which the API sends
+// as null, strings or numbers.
+func optionalString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// resourceServerImport accepts an active server's hostname or numeric ID.
+func resourceServerImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*serverscom.Client)
+	var host *serverscom.Host
+	var err error
+	if id, convErr := strconv.Atoi(d.Id()); convErr == nil {
+		if host, err = client.Hosts.Get(id); err != nil {
+			return nil, err
+		}
+	}
+	if host == nil {
+		if host, err = client.Hosts.GetByTitle(d.Id()); err != nil {
+			return nil, err
+		}
+	}
+	if host == nil {
+		return nil, errors.New(fmt.Sprintf("No active server with hostname or ID %s.", d.Id()))
+	}
+	d.SetId(strconv.Itoa(host.Id))
+	d.Set("hostname", host.Title)
+	d.Set("wait_for_ready", true)
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceServerDelete(d *schema.ResourceData, m interface{}) error {
+	client := m.(*serverscom.Client)
 	hostname := d.Get("hostname").(string)
-	isExist, err := IsServerOrOrderExists(url, email, token, hostname)
+	s, err := lookupServer(client, d)
 	if err != nil {
 		return err
 	}
 
-	if isExist {
-		d.Set("hostname", hostname)
-		return nil
-	} else {
+	if s == nil {
+		return cancelServer(client, d, hostname)
+	}
+	if s.ScheduledReleaseAt != nil {
+		log.Printf("[WARN] Server %s is already scheduled for release at %v", hostname, s.ScheduledReleaseAt)
 		d.SetId("")
-		return errors.New(fmt.Sprintf("Hostname: %s not found.", hostname))
+		return nil
+	}
+	err = client.Hosts.ScheduleRelease(s.Id)
+	if serverscom.IsNotFound(err) {
+		log.Printf("[WARN] Server %s is already gone", hostname)
+	} else if err != nil {
+		return describeAPIError(fmt.Sprintf("Releasing server %s", hostname), err)
 	}
+	d.SetId("")
+	return nil
 }
 
-func resourceServerDelete(d *schema.ResourceData, m interface{}) error {
-	url := m.(*Client).Url
-	email := m.(*Client).Email
-	token := m.(*Client).Token
-	pwd := m.(*Client).Pwd
-	hostname := d.Get("hostname").(string)
-	s, err := GetServer(url, email, token, hostname)
-
+// cancelServer gets rid of a server that has not been delivered yet: through
+// its order while that is still open, otherwise as a pending host.
+func cancelServer(client *serverscom.Client, d *schema.ResourceData, hostname string) error {
+	var order *serverscom.Order
+	var err error
+	if orderID := d.Get("order_id").(int); orderID != 0 {
+		order, err = client.Orders.Get(orderID)
+	} else {
+		order, err = findOpenOrder(client.Orders, hostname)
+	}
 	if err != nil {
 		return err
 	}
-
-	if s != nil && s.ScheduledReleaseAt == nil {
-		err = CancelServer(url, s.Id, email, pwd, token)
-		if err != nil {
+	if order != nil && order.IsOpen() {
+		log.Printf("[INFO] Cancelling order %d of server %s", order.Id, hostname)
+		if _, err = client.Orders.Cancel(order.Id); err != nil {
+			return describeAPIError(fmt.Sprintf("Cancelling order %d (status %d) of server %s", order.Id, order.Status, hostname), err)
+		}
+		if err = waitForOrder(client, order.Id, "cancelled", d.Timeout(schema.TimeoutDelete)); err != nil {
 			return err
 		}
 		d.SetId("")
-	} else if s != nil && s.ScheduledReleaseAt != nil {
-		return errors.New(fmt.Sprintf("Server %s is already in cancellation state!", hostname))
-	} else {
-		return errors.New(fmt.Sprintf("Server %s cannot be released!", hostname))
+		return nil
 	}
-	return resourceServerRead(d, m)
+
+	pending, err := GetPendingServer(client.Hosts, hostname)
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		log.Printf("[WARN] Server %s is already gone", hostname)
+		d.SetId("")
+		return nil
+	}
+	log.Printf("[INFO] Cancelling pending server %s (ID %d)", hostname, pending.Id)
+	if err = client.Hosts.CancelPending(pending.Id); err != nil {
+		return describeAPIError(fmt.Sprintf("Cancelling pending server %s (ID %d)", hostname, pending.Id), err)
+	}
+	conf := &resource.StateChangeConf{
+		Pending: []string{"pending"},
+		Target:  []string{"cancelled"},
+		Refresh: func() (interface{}, string, error) {
+			client.InvalidateCache(serverscom.CachePendingHosts, serverscom.CacheHosts)
+			h, err := GetPendingServer(client.Hosts, hostname)
+			if err != nil {
+				return nil, "", err
+			}
+			if h != nil {
+				return h, "pending", nil
+			}
+			// Delivered hosts leave hosts_pending as well.
+			if h, err = client.Hosts.GetByTitle(hostname); err != nil {
+				return nil, "", err
+			}
+			if h != nil {
+				state := "active"
+				if h.ScheduledReleaseAt != nil {
+					state = fmt.Sprintf("scheduled for release at %v", h.ScheduledReleaseAt)
+				}
+				return nil, "", errors.New(fmt.Sprintf("Server %s was delivered as host %d (%s) instead of being cancelled.", hostname, h.Id, state))
+			}
+			return hostname, "cancelled", nil
+		},
+		Timeout:    d.Timeout(schema.TimeoutDelete),
+		MinTimeout: serverPollInterval,
+	}
+	if _, err = conf.WaitForState(); err != nil {
+		return errors.New(fmt.Sprintf("Pending server %s (ID %d) was not cancelled: %s", hostname, pending.Id, err))
+	}
+	d.SetId("")
+	return nil
 }
 
 func resourceServerUpdate(d *schema.ResourceData, m interface{}) error {
+	client := m.(*serverscom.Client)
 	d.Partial(true)
 	if d.HasChange("hostname") {
-		url := m.(*Client).Url
-		email := m.(*Client).Email
-		token := m.(*Client).Token
-		pwd := m.(*Client).Pwd
-		hostname := d.Id()
-		s, err := GetServer(url, email, token, hostname)
-		if err != nil {
-			return err
-		}
-		if s != nil && s.ScheduledReleaseAt == nil {
-			err = CancelServer(url, s.Id, email, pwd, token)
-			if err != nil {
-				return err
-			}
-			d.SetId("")
-		} else if s != nil && s.ScheduledReleaseAt != nil {
-			return errors.New(fmt.Sprintf("Server %s is already in cancellation state!", hostname))
-		} else {
-			return errors.New(fmt.Sprintf("Server %s cannot be updated!", hostname))
-		}
-		hostname = d.Get("hostname").(string)
-		isExist, err := IsServerOrOrderExists(url, email, token, hostname)
-		if err != nil {
+		old, new := d.GetChange("hostname")
+		if err := renameServer(client, d, old.(string), new.(string)); err != nil {
 			return err
 		}
-		if isExist {
-			return errors.New(fmt.Sprintf("Order cannot be created. Hostname: %s is not unique.", hostname))
-		}
-		err = AddToCart(url, email, token, hostname, d.Get("config").(string))
-		if err != nil {
-			return err
-		}
-		err = CheckoutOrder(url, email, token)
-		if err != nil {
+		d.SetPartial("hostname")
+	}
+	if actions := serverUpdateActions(d); len(actions) > 0 {
+		if err := changeServer(client, d, actions); err != nil {
 			return err
 		}
-		d.SetId(hostname)
-		d.SetPartial("hostname")
 	}
 	d.Partial(false)
 
 	return resourceServerRead(d, m)
 }
 
+// renameServer changes the title of the host in place. Servers that are
+// still on order have no host to rename yet.
+func renameServer(client *serverscom.Client, d *schema.ResourceData, old, new string) error {
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return errors.New(fmt.Sprintf("Server %s cannot be renamed before it is active.", old))
+	}
+	isExist, err := IsServerOrOrderExists(client, new, 0)
+	if err != nil {
+		return err
+	}
+	if isExist {
+		return errors.New(fmt.Sprintf("Server %s cannot be renamed. Hostname: %s is not unique.", old, new))
+	}
+	log.Printf("[INFO] Renaming server %s to %s", old, new)
+	if _, err = client.Hosts.UpdateTitle(id, new); err != nil {
+		return describeAPIError(fmt.Sprintf("Renaming server %s to %s", old, new), err)
+	}
+	return nil
+}
+
+// changeServer applies config changes to a running server: an upgrade order
+// for new hardware, then a reinstall for a new OS.
+func changeServer(client *serverscom.Client, d *schema.ResourceData, actions []string) error {
+	hostname := d.Get("hostname").(string)
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return errors.New(fmt.Sprintf("Server %s cannot be changed before it is active.", hostname))
+	}
+	config, err := buildServerOrder(d, hostname)
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		switch action {
+		case serverActionUpgrade:
+			log.Printf("[INFO] Ordering an upgrade of server %s", hostname)
+			order, err := client.Hosts.Upgrade(id, buildServerUpgrade(config))
+			if err != nil {
+				return describeAPIError(fmt.Sprintf("Upgrading server %s", hostname), err)
+			}
+			// The upgrade is ordered; save it before waiting so that it is not
+			// ordered again.
+			d.Set("upgrade_order_id", order.Id)
+			d.SetPartial("upgrade_order_id")
+			for _, key := range serverUpgradeKeys {
+				d.SetPartial(key)
+			}
+			if d.Get("wait_for_ready").(bool) {
+				if err = waitForOrder(client, order.Id, "completed", d.Timeout(schema.TimeoutUpdate)); err != nil {
+					return err
+				}
+			}
+		case serverActionReinstall:
+			log.Printf("[INFO] Reinstalling server %s with %s %s", hostname, config.OS.Name, config.OS.Version)
+			if err = client.Hosts.Reinstall(id, &config.OS); err != nil {
+				return describeAPIError(fmt.Sprintf("Reinstalling server %s", hostname), err)
+			}
+			d.SetPartial("os")
+		}
+	}
+	return nil
+}
+
+// waitForOrder polls until the order reaches target, "completed" or
+// "cancelled".
+func waitForOrder(client *serverscom.Client, orderID int, target string, timeout time.Duration) error {
+	log.Printf("[INFO] Waiting up to %s for order %d to be %s", timeout, orderID, target)
+	conf := &resource.StateChangeConf{
+		Pending: []string{"processing"},
+		Target:  []string{target},
+		Refresh: func() (interface{}, string, error) {
+			client.InvalidateCache(serverscom.CacheOrders)
+			order, err := client.Orders.Get(orderID)
+			if err != nil {
+				return nil, "", err
+			}
+			if order == nil {
+				return nil, "", errors.New(fmt.Sprintf("Order %d not found.", orderID))
+			}
+			switch order.Status {
+			case serverscom.OrderStatusCompleted:
+				return order, "completed", nil
+			case serverscom.OrderStatusCancelled:
+				return order, "cancelled", nil
+			}
+			return order, "processing", nil
+		},
+		Timeout:    timeout,
+		Delay:      serverPollInterval,
+		MinTimeout: serverPollInterval,
+	}
+	if _, err := conf.WaitForState(); err != nil {
+		return errors.New(fmt.Sprintf("Order %d was not %s: %s", orderID, target, err))
+	}
+	return nil
+}
+
+func resourceServerSchema() map[string]*schema.Schema {
+	s := map[string]*schema.Schema{
+		"hostname": &schema.Schema{
+			Type:         schema.TypeString,
+			Required:     true,
+			ValidateFunc: validation.NoZeroValues,
+		},
+		"config": &schema.Schema{
+			Type:          schema.TypeString,
+			Optional:      true,
+			Deprecated:    "Use location_id, server_model_id, ram_size, os, hdds, disks, uplinks, public_bandwidth, ips and ipv6 instead.",
+			ConflictsWith: serverConfigKeys,
+			ValidateFunc:  validateRawConfig,
+			// The raw config is only used to order the server. Read fills in
+			// the structured attributes, so dropping it later changes nothing.
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				return new == "" && d.Id() != ""
+			},
+		},
+		"order_id": &schema.Schema{
+			Type:     schema.TypeInt,
+			Computed: true,
+		},
+		// The upgrade order still being processed, 0 when there is none.
+		"upgrade_order_id": &schema.Schema{
+			Type:     schema.TypeInt,
+			Computed: true,
+		},
+		"wait_for_ready": &schema.Schema{
+			Type:     schema.TypeBool,
+			Optional: true,
+			Default:  true,
+		},
+		// How planned config changes will be applied to the running server:
+		// upgrade and/or reinstall. Empty once they have been applied.
+		"update_actions": &schema.Schema{
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+
+		"server_id": &schema.Schema{
+			Type:     schema.TypeInt,
+			Computed: true,
+		},
+		"public_ipv4": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"public_netmask": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"private_ipv4": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"private_netmask": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"location_name": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"rack_name": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"project_name": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"lease_end": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"scheduled_release_at": &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"networks": &schema.Schema{
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"id": &schema.Schema{
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					"host_ip": &schema.Schema{
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"pool_type": &schema.Schema{
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"size": &schema.Schema{
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					"netmask": &schema.Schema{
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+				},
+			},
+		},
+	}
+	for k, v := range serverConfigSchema() {
+		s[k] = v
+	}
+	return s
+}
 
 func resourceServer() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceServerCreate,
-		Read:   resourceServerRead,
-		Delete: resourceServerDelete,
-		Update: resourceServerUpdate,
+		Create:        resourceServerCreate,
+		Read:          resourceServerRead,
+		Delete:        resourceServerDelete,
+		Update:        resourceServerUpdate,
+		CustomizeDiff: resourceServerCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			State: resourceServerImport,
+		},
+
+		Schema:        resourceServerSchema(),
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceServerV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceServerStateUpgradeV0,
+			},
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(2 * time.Hour),
+			Update: schema.DefaultTimeout(2 * time.Hour),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+	}
+}
 
+// resourceServerV0 is the schema version 0 state was written with, when
+// servers were keyed by hostname and only had hostname and config.
+func resourceServerV0() *schema.Resource {
+	return &schema.Resource{
 		Schema: map[string]*schema.Schema{
 			"hostname": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
-				ValidateFunc: validation.NoZeroValues,
 			},
 			"config": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
-				ValidateFunc: validation.NoZeroValues,
 			},
 		},
 	}
-}
\ No newline at end of file
+}
+
+func resourceServerStateUpgradeV0(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	hostname, _ := rawState["hostname"].(string)
+	if hostname == "" {
+		hostname, _ = rawState["id"].(string)
+	}
+	if client, ok := meta.(*serverscom.Client); ok && hostname != "" {
+		host, err := client.Hosts.GetByTitle(hostname)
+		if err != nil {
+			return nil, err
+		}
+		if host != nil {
+			log.Printf("[INFO] Upgrading state of server %s to host ID %d", hostname, host.Id)
+			rawState["id"] = strconv.Itoa(host.Id)
+			rawState["server_id"] = host.Id
+			return rawState, nil
+		}
+	}
+	// A server that is not active yet keeps its hostname as ID. Read looks
+	// it up by hostname and switches to the host ID once it is delivered.
+	return rawState, nil
+}
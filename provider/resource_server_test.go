@@ -0,0 +1,451 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"regexp"
+	"servers.com/terraform-provider/serverscom"
+	"servers.com/terraform-provider/serverscom/serverscomtest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAccServer_basic(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	var host serverscom.Host
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccServerConfig("web-1", 32),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckServerExists(fake, "serverscom_server.test", &host),
+					resource.TestCheckResourceAttr("serverscom_server.test", "hostname", "web-1"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "location_name", "LOC1"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "ram_size", "32"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "os.0.arch", "x86_64"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "hdds.#", "2"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "disks.0.raid", "1"),
+					resource.TestCheckResourceAttrSet("serverscom_server.test", "order_id"),
+					resource.TestCheckResourceAttrSet("serverscom_server.test", "public_ipv4"),
+				),
+			},
+			{
+				// A new hostname renames the same host.
+				Config: testAccServerConfig("web-2", 32),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckServerSameHost(fake, "serverscom_server.test", &host),
+					resource.TestCheckResourceAttr("serverscom_server.test", "hostname", "web-2"),
+				),
+			},
+			{
+				// More RAM is an upgrade order on the same host.
+				Config: testAccServerConfig("web-2", 64),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckServerSameHost(fake, "serverscom_server.test", &host),
+					resource.TestCheckResourceAttr("serverscom_server.test", "ram_size", "64"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "update_actions.#", "0"),
+				),
+			},
+			{
+				ResourceName:            "serverscom_server.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"order_id", "update_actions"},
+			},
+		},
+	})
+}
+
+func TestAccServer_drift(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	var host serverscom.Host
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccServerConfig("db-1", 32),
+				Check:  testAccCheckServerExists(fake, "serverscom_server.test", &host),
+			},
+			{
+				// RAM changed in the portal shows up as a diff.
+				PreConfig: func() {
+					client := testAccClient(t, fake)
+					config, err := client.Hosts.GetConfiguration(host.Id)
+					if err != nil {
+						t.Fatal(err)
+					}
+					config.RamSize = 16
+					fake.SetConfiguration(host.Id, *config)
+				},
+				Config:             testAccServerConfig("db-1", 32),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccServer_upgradeWithoutWaiting(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	var host serverscom.Host
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccServerConfig("app-1", 32),
+				Check:  testAccCheckServerExists(fake, "serverscom_server.test", &host),
+			},
+			{
+				PreConfig: func() { fake.AutoAdvance = false },
+				Config:    testAccServerConfigWait("app-1", 64, false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("serverscom_server.test", "ram_size", "64"),
+					resource.TestMatchResourceAttr("serverscom_server.test", "upgrade_order_id", regexp.MustCompile("^[1-9]")),
+				),
+			},
+			{
+				// The open upgrade is not ordered again.
+				Config: testAccServerConfigWait("app-1", 64, false),
+				Check: func(*terraform.State) error {
+					if n := len(fake.Orders()); n != 2 {
+						return errors.New(fmt.Sprintf("Expected the server order and one upgrade order, got %d orders.", n))
+					}
+					return nil
+				},
+			},
+			{
+				PreConfig: func() {
+					fake.Advance()
+					fake.AutoAdvance = true
+				},
+				Config: testAccServerConfigWait("app-1", 64, false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("serverscom_server.test", "upgrade_order_id", "0"),
+					func(*terraform.State) error {
+						config, err := testAccClient(t, fake).Hosts.GetConfiguration(host.Id)
+						if err != nil {
+							return err
+						}
+						if config.RamSize != 64 {
+							return errors.New(fmt.Sprintf("Expected 64 GB of RAM after the upgrade, got %d.", config.RamSize))
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccServer_releasedOutsideTerraform(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	var host serverscom.Host
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccServerConfig("tmp-1", 32),
+				Check:  testAccCheckServerExists(fake, "serverscom_server.test", &host),
+			},
+			{
+				// The released host is dropped from state and planned again.
+				PreConfig: func() {
+					client := testAccClient(t, fake)
+					if err := client.Hosts.ScheduleRelease(host.Id); err != nil {
+						t.Fatal(err)
+					}
+					fake.Advance()
+				},
+				Config:             testAccServerConfig("tmp-1", 32),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccServer_noWait(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	var host serverscom.Host
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				// The order is left open, so the server stays on order.
+				PreConfig: func() { fake.AutoAdvance = false },
+				Config:    testAccServerConfigWait("queue-1", 32, false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("serverscom_server.test", "id", regexp.MustCompile("^order-")),
+					resource.TestCheckResourceAttr("serverscom_server.test", "networks.#", "0"),
+					resource.TestCheckNoResourceAttr("serverscom_server.test", "public_ipv4"),
+				),
+			},
+			{
+				// Once delivered, a refresh switches to the host.
+				PreConfig: func() {
+					fake.Advance()
+					fake.Advance()
+					fake.AutoAdvance = true
+				},
+				Config: testAccServerConfigWait("queue-1", 32, false),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckServerExists(fake, "serverscom_server.test", &host),
+					resource.TestCheckResourceAttr("serverscom_server.test", "networks.#", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccServer_migrateFromRawConfig(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	var host serverscom.Host
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccServerRawConfig(t, "legacy-1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckServerExists(fake, "serverscom_server.test", &host),
+					resource.TestCheckResourceAttr("serverscom_server.test", "location_id", "1"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "server_model_id", "10"),
+					resource.TestCheckResourceAttr("serverscom_server.test", "ram_size", "32"),
+				),
+			},
+			{
+				// The same server described with the structured attributes.
+				Config:   testAccServerConfig("legacy-1", 32),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccServer_resumeOrder(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	var host serverscom.Host
+	var order *serverscom.Order
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				// An earlier run placed the order and then failed.
+				PreConfig: func() {
+					fake.AutoAdvance = false
+					client := testAccClient(t, fake)
+					if _, err := client.Orders.AddToCart(testAccServerOrder(t, "resume-1")); err != nil {
+						t.Fatal(err)
+					}
+					var err error
+					if order, err = client.Orders.Checkout(); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: testAccServerConfigWait("resume-1", 32, false),
+				Check: func(s *terraform.State) error {
+					if n := len(fake.Orders()); n != 1 {
+						return errors.New(fmt.Sprintf("Expected the open order to be resumed, got %d orders.", n))
+					}
+					return resource.TestCheckResourceAttr("serverscom_server.test", "order_id", strconv.Itoa(order.Id))(s)
+				},
+			},
+			{
+				PreConfig: func() {
+					fake.Advance()
+					fake.Advance()
+					fake.AutoAdvance = true
+				},
+				Config: testAccServerConfigWait("resume-1", 32, false),
+				Check:  testAccCheckServerExists(fake, "serverscom_server.test", &host),
+			},
+		},
+	})
+}
+
+func TestAccServer_waitTimeout(t *testing.T) {
+	fake := testAccFake(t)
+	defer fake.Close()
+	config := strings.Replace(testAccServerConfig("slow-1", 32), "  os {", `  timeouts {
+    create = "1s"
+  }
+
+  os {`, 1)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckServerDestroy(fake),
+		Steps: []resource.TestStep{
+			{
+				PreConfig:   func() { fake.AutoAdvance = false },
+				Config:      config,
+				ExpectError: regexp.MustCompile("did not become active(.|\\n)*untaint"),
+			},
+			{
+				// The failed server is tainted and planned for replacement.
+				PreConfig:          func() { fake.AutoAdvance = true },
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccCheckServerExists(fake *serverscomtest.Server, name string, host *serverscom.Host) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return errors.New(fmt.Sprintf("Not found: %s", name))
+		}
+		for _, h := range fake.Hosts() {
+			if strconv.Itoa(h.Id) == rs.Primary.ID {
+				*host = h
+				return nil
+			}
+		}
+		return errors.New(fmt.Sprintf("No active host with ID %s.", rs.Primary.ID))
+	}
+}
+
+func testAccCheckServerSameHost(fake *serverscomtest.Server, name string, host *serverscom.Host) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		var current serverscom.Host
+		if err := testAccCheckServerExists(fake, name, &current)(s); err != nil {
+			return err
+		}
+		if current.Id != host.Id {
+			return errors.New(fmt.Sprintf("Server was replaced: host %d became %d.", host.Id, current.Id))
+		}
+		if n := len(fake.Orders()); n > 2 {
+			return errors.New(fmt.Sprintf("Expected the server order and at most one upgrade order, got %d orders.", n))
+		}
+		return nil
+	}
+}
+
+func testAccCheckServerDestroy(fake *serverscomtest.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "serverscom_server" {
+				continue
+			}
+			for _, h := range fake.Hosts() {
+				if strconv.Itoa(h.Id) == rs.Primary.ID && h.ScheduledReleaseAt == nil {
+					return errors.New(fmt.Sprintf("Server %s was not released.", h.Title))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func testAccServerConfig(hostname string, ram int) string {
+	return testAccServerConfigWait(hostname, ram, true)
+}
+
+func testAccServerConfigWait(hostname string, ram int, wait bool) string {
+	return fmt.Sprintf(`
+resource "serverscom_server" "test" {
+  hostname        = %q
+  location_id     = 1
+  server_model_id = 10
+  ram_size        = %d
+  wait_for_ready  = %t
+
+  os {
+    name    = "Ubuntu"
+    version = "18.04"
+  }
+
+  hdds {
+    interface = 1
+    hdd_id    = 5
+    hdd_name  = "SSD 480GB"
+    hdd_size  = 480
+  }
+  hdds {
+    interface = 1
+    hdd_id    = 5
+    hdd_name  = "SSD 480GB"
+    hdd_size  = 480
+  }
+
+  disks {
+    slots = [0, 1]
+    raid  = 1
+
+    partition {
+      target = "/"
+      fs     = "ext4"
+      fill   = true
+    }
+  }
+
+  uplinks {
+    public = 1000
+  }
+  public_bandwidth = 20
+}
+`, hostname, ram, wait)
+}
+
+// testAccServerOrder is what testAccServerConfig(hostname, 32) orders.
+func testAccServerOrder(t *testing.T, hostname string) string {
+	slot := serverscom.OrderHddSlot{Interface: 1, Hdd: &serverscom.OrderHdd{Id: 5, Name: "SSD 480GB", Size: 480}}
+	order := &serverscom.ServerOrder{
+		Hosts:         []serverscom.OrderHost{{Hostname: hostname}},
+		LocationId:    1,
+		ServerModelId: 10,
+		RamSize:       32,
+		OS:            serverscom.OrderOS{Name: "Ubuntu", Version: "18.04", Arch: "x86_64"},
+		Hdds:          map[string]serverscom.OrderHddSlot{"0": slot, "1": slot},
+		Disks: []serverscom.OrderDiskGroup{{
+			Disks:      []int{0, 1},
+			Raid:       1,
+			Partitions: []serverscom.OrderPartition{{Target: "/", Fs: "ext4", Fill: true}},
+		}},
+		Uplinks:         serverscom.OrderUplinks{Public: 1000},
+		PublicBandwidth: 20,
+	}
+	payload, err := order.CartPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// testAccServerRawConfig is testAccServerConfig(hostname, 32) written with
+// the deprecated raw config.
+func testAccServerRawConfig(t *testing.T, hostname string) string {
+	return fmt.Sprintf(`
+resource "serverscom_server" "test" {
+  hostname = %q
+  config   = %q
+}
+`, hostname, testAccServerOrder(t, "%s"))
+}
@@ -0,0 +1,508 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+	"log"
+	"servers.com/terraform-provider/serverscom"
+	"strings"
+)
+
+// serverConfigKeys are the structured attributes that describe the hardware
+// and OS of a serverscom_server. The deprecated raw JSON config conflicts
+// with all of them.
+var serverConfigKeys = []string{
+	"location_id", "server_model_id", "ram_size", "os", "hdds", "disks",
+	"uplinks", "public_bandwidth", "ips", "ipv6",
+}
+
+// serverUpgradeKeys can be changed on a running server with an upgrade
+// order. Changing os reinstalls it; location_id and server_model_id replace it.
+var serverUpgradeKeys = []string{
+	"ram_size", "hdds", "disks", "uplinks", "public_bandwidth", "ips", "ipv6",
+}
+
+const (
+	serverActionUpgrade   = "upgrade"
+	serverActionReinstall = "reinstall"
+)
+
+func serverConfigSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		// A different location or model means different hardware, so the
+		// server is replaced.
+		"location_id": &schema.Schema{
+			Type:          schema.TypeInt,
+			Optional:      true,
+			Computed:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"config"},
+			ValidateFunc:  validation.IntAtLeast(1),
+		},
+		"server_model_id": &schema.Schema{
+			Type:          schema.TypeInt,
+			Optional:      true,
+			Computed:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"config"},
+			ValidateFunc:  validation.IntAtLeast(1),
+		},
+		"ram_size": &schema.Schema{
+			Type:          schema.TypeInt,
+			Optional:      true,
+			Computed:      true,
+			ConflictsWith: []string{"config"},
+			ValidateFunc:  validation.IntAtLeast(1),
+		},
+		"os": &schema.Schema{
+			Type:          schema.TypeList,
+			Optional:      true,
+			Computed:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{"config"},
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": &schema.Schema{
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.NoZeroValues,
+					},
+					"version": &schema.Schema{
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.NoZeroValues,
+					},
+					// Defaults to x86_64 when ordering.
+					"arch": &schema.Schema{
+						Type:     schema.TypeString,
+						Optional: true,
+						Computed: true,
+					},
+				},
+			},
+		},
+		// One entry per drive bay, in bay order. Leave hdd_id unset for an
+		// empty bay.
+		"hdds": &schema.Schema{
+			Type:          schema.TypeList,
+			Optional:      true,
+			Computed:      true,
+			ConflictsWith: []string{"config"},
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"interface": &schema.Schema{
+						Type:     schema.TypeInt,
+						Required: true,
+					},
+					"physical_size": &schema.Schema{
+						Type:     schema.TypeInt,
+						Optional: true,
+					},
+					"hdd_id": &schema.Schema{
+						Type:     schema.TypeInt,
+						Optional: true,
+					},
+					"hdd_name": &schema.Schema{
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"hdd_size": &schema.Schema{
+						Type:     schema.TypeInt,
+						Optional: true,
+					},
+				},
+			},
+		},
+		"disks": &schema.Schema{
+			Type:          schema.TypeList,
+			Optional:      true,
+			Computed:      true,
+			ConflictsWith: []string{"config"},
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					// Indexes into hdds of the drives in this array.
+					"slots": &schema.Schema{
+						Type:     schema.TypeList,
+						Required: true,
+						MinItems: 1,
+						Elem:     &schema.Schema{Type: schema.TypeInt},
+					},
+					"raid": &schema.Schema{
+						Type:         schema.TypeInt,
+						Optional:     true,
+						ValidateFunc: validation.IntInSlice([]int{0, 1, 5, 6, 10}),
+					},
+					"partition": &schema.Schema{
+						Type:     schema.TypeList,
+						Required: true,
+						MinItems: 1,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"target": &schema.Schema{
+									Type:         schema.TypeString,
+									Required:     true,
+									ValidateFunc: validation.NoZeroValues,
+								},
+								"fs": &schema.Schema{
+									Type:         schema.TypeString,
+									Required:     true,
+									ValidateFunc: validation.StringInSlice([]string{"ext2", "ext3", "ext4", "xfs", "swap", "reiserfs"}, false),
+								},
+								"size": &schema.Schema{
+									Type:         schema.TypeInt,
+									Optional:     true,
+									ValidateFunc: validation.IntAtLeast(0),
+								},
+								"fill": &schema.Schema{
+									Type:     schema.TypeBool,
+									Optional: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"uplinks": &schema.Schema{
+			Type:          schema.TypeList,
+			Optional:      true,
+			Computed:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{"config"},
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"public": &schema.Schema{
+						Type:     schema.TypeInt,
+						Required: true,
+					},
+					"private": &schema.Schema{
+						Type:     schema.TypeInt,
+						Optional: true,
+					},
+				},
+			},
+		},
+		"public_bandwidth": &schema.Schema{
+			Type:          schema.TypeInt,
+			Optional:      true,
+			Computed:      true,
+			ConflictsWith: []string{"config"},
+		},
+		"ips": &schema.Schema{
+			Type:          schema.TypeInt,
+			Optional:      true,
+			Computed:      true,
+			ConflictsWith: []string{"config"},
+			ValidateFunc:  validation.IntAtLeast(0),
+		},
+		"ipv6": &schema.Schema{
+			Type:          schema.TypeBool,
+			Optional:      true,
+			Computed:      true,
+			ConflictsWith: []string{"config"},
+		},
+	}
+}
+
+// validateRawConfig checks that the deprecated config is JSON once the
+// hostname has been put in place of %s.
+func validateRawConfig(v interface{}, k string) ([]string, []error) {
+	config := v.(string)
+	if !strings.Contains(config, "%s") {
+		return nil, []error{errors.New(fmt.Sprintf("%s must contain a %%s placeholder for the hostname.", k))}
+	}
+	var js interface{}
+	if err := json.Unmarshal([]byte(fmt.Sprintf(config, "hostname")), &js); err != nil {
+		return nil, []error{errors.New(fmt.Sprintf("%s is not valid JSON: %s", k, err))}
+	}
+	return nil, nil
+}
+
+// configGetter is what ResourceData and ResourceDiff have in common, so that
+// the same code builds the order at apply time and validates it at plan time.
+type configGetter interface {
+	Get(key string) interface{}
+}
+
+// cartPayload renders the cart item for hostname from either the structured
+// attributes or the deprecated raw JSON config.
+func cartPayload(d configGetter, hostname string) (string, error) {
+	if config := d.Get("config").(string); config != "" {
+		return fmt.Sprintf(config, hostname), nil
+	}
+	order, err := buildServerOrder(d, hostname)
+	if err != nil {
+		return "", err
+	}
+	return order.CartPayload()
+}
+
+func buildServerOrder(d configGetter, hostname string) (*serverscom.ServerOrder, error) {
+	order := &serverscom.ServerOrder{
+		Hosts:           []serverscom.OrderHost{{Hostname: hostname}},
+		LocationId:      d.Get("location_id").(int),
+		ServerModelId:   d.Get("server_model_id").(int),
+		RamSize:         d.Get("ram_size").(int),
+		Hdds:            map[string]serverscom.OrderHddSlot{},
+		Disks:           []serverscom.OrderDiskGroup{},
+		PublicBandwidth: d.Get("public_bandwidth").(int),
+		Ips:             d.Get("ips").(int),
+		Ipv6:            d.Get("ipv6").(bool),
+	}
+	var missing []string
+	for _, key := range []string{"location_id", "server_model_id", "ram_size"} {
+		if d.Get(key).(int) == 0 {
+			missing = append(missing, key)
+		}
+	}
+
+	if list := d.Get("os").([]interface{}); len(list) > 0 && list[0] != nil {
+		osBlock := list[0].(map[string]interface{})
+		order.OS = serverscom.OrderOS{
+			Name:    osBlock["name"].(string),
+			Arch:    osBlock["arch"].(string),
+			Version: osBlock["version"].(string),
+		}
+		if order.OS.Arch == "" {
+			order.OS.Arch = "x86_64"
+		}
+	} else {
+		missing = append(missing, "os")
+	}
+	if len(missing) > 0 {
+		return nil, errors.New(fmt.Sprintf("Either config or %s must be set.", strings.Join(missing, ", ")))
+	}
+
+	for i, v := range d.Get("hdds").([]interface{}) {
+		slot := serverscom.OrderHddSlot{}
+		if v != nil {
+			hdd := v.(map[string]interface{})
+			slot.Interface = hdd["interface"].(int)
+			slot.PhysicalSize = hdd["physical_size"].(int)
+			if id := hdd["hdd_id"].(int); id != 0 {
+				slot.Hdd = &serverscom.OrderHdd{
+					Id:   id,
+					Name: hdd["hdd_name"].(string),
+					Size: hdd["hdd_size"].(int),
+				}
+			}
+		}
+		order.Hdds[serverscom.SlotKey(i)] = slot
+	}
+
+	used := map[int]int{}
+	for g, v := range d.Get("disks").([]interface{}) {
+		if v == nil {
+			continue
+		}
+		disk := v.(map[string]interface{})
+		group := serverscom.OrderDiskGroup{Raid: disk["raid"].(int)}
+		for _, s := range disk["slots"].([]interface{}) {
+			slot := s.(int)
+			hdd, ok := order.Hdds[serverscom.SlotKey(slot)]
+			if !ok || hdd.Hdd == nil {
+				return nil, errors.New(fmt.Sprintf("disks.%d uses bay %d, which has no drive in hdds.", g, slot))
+			}
+			if other, ok := used[slot]; ok {
+				return nil, errors.New(fmt.Sprintf("disks.%d uses bay %d, which disks.%d already uses.", g, slot, other))
+			}
+			used[slot] = g
+			group.Disks = append(group.Disks, slot)
+		}
+		if min := minRaidDisks(group.Raid); len(group.Disks) < min {
+			return nil, errors.New(fmt.Sprintf("disks.%d: RAID %d needs at least %d drives.", g, group.Raid, min))
+		}
+		fills := 0
+		for _, p := range disk["partition"].([]interface{}) {
+			part := p.(map[string]interface{})
+			partition := serverscom.OrderPartition{
+				Target: part["target"].(string),
+				Fs:     part["fs"].(string),
+				Size:   part["size"].(int),
+				Fill:   part["fill"].(bool),
+			}
+			if partition.Fill {
+				fills++
+			} else if partition.Size == 0 {
+				return nil, errors.New(fmt.Sprintf("disks.%d: partition %s needs a size or fill = true.", g, partition.Target))
+			}
+			group.Partitions = append(group.Partitions, partition)
+		}
+		if fills > 1 {
+			return nil, errors.New(fmt.Sprintf("disks.%d: only one partition can fill the remaining space.", g))
+		}
+		order.Disks = append(order.Disks, group)
+	}
+
+	if list := d.Get("uplinks").([]interface{}); len(list) > 0 && list[0] != nil {
+		uplinks := list[0].(map[string]interface{})
+		order.Uplinks = serverscom.OrderUplinks{
+			Public:  uplinks["public"].(int),
+			Private: uplinks["private"].(int),
+		}
+	}
+	return order, nil
+}
+
+// setServerConfig writes the hardware and OS the server actually has into
+// the structured attributes, so that changes made outside Terraform show up
+// as diffs against the declared configuration. Attributes that are not
+// declared are computed and just take the server's values.
+func setServerConfig(d *schema.ResourceData, config *serverscom.HostConfiguration) error {
+	d.Set("location_id", config.LocationId)
+	d.Set("server_model_id", config.ServerModelId)
+	d.Set("ram_size", config.RamSize)
+	d.Set("public_bandwidth", config.PublicBandwidth)
+	d.Set("ips", config.Ips)
+	d.Set("ipv6", config.Ipv6)
+
+	osList := []interface{}{}
+	if config.OS.Name != "" {
+		osList = append(osList, map[string]interface{}{
+			"name":    config.OS.Name,
+			"version": config.OS.Version,
+			"arch":    config.OS.Arch,
+		})
+	}
+	if err := d.Set("os", osList); err != nil {
+		return err
+	}
+
+	hdds := make([]interface{}, len(config.Hdds))
+	for i := range hdds {
+		slot := config.Hdds[serverscom.SlotKey(i)]
+		hdd := map[string]interface{}{
+			"interface":     slot.Interface,
+			"physical_size": slot.PhysicalSize,
+		}
+		if slot.Hdd != nil {
+			hdd["hdd_id"] = slot.Hdd.Id
+			hdd["hdd_name"] = slot.Hdd.Name
+			hdd["hdd_size"] = slot.Hdd.Size
+		}
+		hdds[i] = hdd
+	}
+	if err := d.Set("hdds", hdds); err != nil {
+		return err
+	}
+
+	disks := make([]interface{}, 0, len(config.Disks))
+	for _, group := range config.Disks {
+		partitions := make([]interface{}, 0, len(group.Partitions))
+		for _, p := range group.Partitions {
+			partitions = append(partitions, map[string]interface{}{
+				"target": p.Target,
+				"fs":     p.Fs,
+				"size":   p.Size,
+				"fill":   p.Fill,
+			})
+		}
+		disks = append(disks, map[string]interface{}{
+			"slots":     group.Disks,
+			"raid":      group.Raid,
+			"partition": partitions,
+		})
+	}
+	if err := d.Set("disks", disks); err != nil {
+		return err
+	}
+
+	uplinks := []interface{}{}
+	if config.Uplinks.Public != 0 || config.Uplinks.Private != 0 {
+		uplinks = append(uplinks, map[string]interface{}{
+			"public":  config.Uplinks.Public,
+			"private": config.Uplinks.Private,
+		})
+	}
+	return d.Set("uplinks", uplinks)
+}
+
+func minRaidDisks(level int) int {
+	switch level {
+	case 1:
+		return 2
+	case 5:
+		return 3
+	case 6, 10:
+		return 4
+	}
+	return 1
+}
+
+type changeGetter interface {
+	HasChange(key string) bool
+}
+
+// serverUpdateActions lists, in the order Update applies them, what has to
+// happen to a running server for the planned config changes.
+func serverUpdateActions(d changeGetter) []string {
+	var actions []string
+	for _, key := range serverUpgradeKeys {
+		if d.HasChange(key) {
+			actions = append(actions, serverActionUpgrade)
+			break
+		}
+	}
+	if d.HasChange("os") {
+		actions = append(actions, serverActionReinstall)
+	}
+	return actions
+}
+
+// buildServerUpgrade takes the hardware from an order built from d. Attributes
+// left out of the configuration hold what Read found on the server, so the
+// upgrade leaves them as they are.
+func buildServerUpgrade(order *serverscom.ServerOrder) *serverscom.ServerUpgrade {
+	return &serverscom.ServerUpgrade{
+		RamSize:         order.RamSize,
+		Hdds:            order.Hdds,
+		Disks:           order.Disks,
+		Uplinks:         order.Uplinks,
+		PublicBandwidth: order.PublicBandwidth,
+		Ips:             order.Ips,
+		Ipv6:            order.Ipv6,
+	}
+}
+
+// resourceServerCustomizeDiff rejects configurations that could never be
+// ordered, so that they fail at plan time instead of halfway through apply.
+// For a running server it also shows how the changes will be applied.
+func resourceServerCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	config := d.Get("config").(string)
+	if d.Id() != "" {
+		replaced := d.HasChange("location_id") || d.HasChange("server_model_id")
+		if d.HasChange("config") && config != "" {
+			// A raw config can only be ordered, never applied to a running
+			// server. Dropping it in favour of the structured attributes is
+			// not a change at all, see the config schema.
+			if err := d.ForceNew("config"); err != nil {
+				return err
+			}
+			replaced = true
+		}
+		actions := serverUpdateActions(d)
+		if replaced || len(actions) == 0 {
+			// A replacement is diffed, and checked here, again as a new server.
+			return nil
+		}
+		// Show in the plan how the running server will be changed.
+		log.Printf("[INFO] Server %s will be changed with: %s", d.Get("hostname").(string), strings.Join(actions, ", "))
+		if err := d.SetNew("update_actions", actions); err != nil {
+			return err
+		}
+	} else if config != "" {
+		return nil
+	}
+	// Attributes left out are computed, so an unknown value may just mean
+	// it is not set. Ordering checks those again.
+	for _, key := range []string{"hostname", "location_id", "server_model_id", "ram_size", "os"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+	_, err := buildServerOrder(d, d.Get("hostname").(string))
+	return err
+}
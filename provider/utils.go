@@ -3,34 +3,27 @@ package provider
 import (
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"time"
+	"servers.com/terraform-provider/serverscom"
+	"strings"
 )
 
-func GetResponse(url, email, token, method string, data io.Reader) (*[]byte, error) {
-	req, err := http.NewRequest(method, url, data)
-	if err != nil {
-		return nil, err
+// describeAPIError prefixes err with the action that failed. Validation
+// failures are spelled out field by field so the offending attribute in the
+// configuration is easy to find.
+func describeAPIError(action string, err error) error {
+	var apiErr *serverscom.APIError
+	if !errors.As(err, &apiErr) {
+		return errors.New(fmt.Sprintf("%s: %s", action, err))
 	}
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("X-User-Email", email)
-	req.Header.Set("X-User-Token", token)
-
-	timeout := time.Duration(60 * time.Second)
-	client := &http.Client{
-		Timeout: timeout,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	if serverscom.IsValidation(err) && len(apiErr.FieldErrors) > 0 {
+		var fields []string
+		for _, field := range apiErr.Fields() {
+			fields = append(fields, fmt.Sprintf("%s (%s)", field, strings.Join(apiErr.FieldErrors[field], ", ")))
+		}
+		return errors.New(fmt.Sprintf("%s: the API rejected %s.", action, strings.Join(fields, ", ")))
 	}
-	defer resp.Body.Close()
-	b, _ := ioutil.ReadAll(resp.Body)
-	client = nil
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
-		return &b, nil
+	if serverscom.IsConflict(err) {
+		return errors.New(fmt.Sprintf("%s: conflicts with the current state of the account: %s", action, err))
 	}
-	return &b, errors.New(fmt.Sprintf("Error occured. %d. %s", resp.StatusCode, string(b)))
+	return errors.New(fmt.Sprintf("%s: %s", action, err))
 }
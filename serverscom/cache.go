@@ -0,0 +1,89 @@
+package serverscom
+
+import "sync"
+
+// Cache keys of the list endpoints, for Client.InvalidateCache.
+const (
+	CacheHosts        = "hosts"
+	CachePendingHosts = "hosts_pending"
+	CacheOrders       = "orders"
+	CacheL2Segments   = "l2_segments"
+	CacheDNSRecords   = "dns_records"
+)
+
+// listCache keeps the decoded result of each list endpoint for the lifetime
+// of the client, so that many resources looking themselves up during one
+// Terraform run share a single download. Concurrent misses for the same key
+// are coalesced into one request. Any mutation through the client drops the
+// whole cache, since most of them show up in more than one list.
+type listCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newListCache() *listCache {
+	return &listCache{entries: map[string]*cacheEntry{}}
+}
+
+// get returns the cached value for key, calling fetch to fill it on a miss.
+// Failed fetches are not cached.
+func (c *listCache) get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		<-e.done
+		return e.value, e.err
+	}
+	e := &cacheEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.value, e.err = fetch()
+	close(e.done)
+
+	if e.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	return e.value, e.err
+}
+
+// peek returns the value for key if it has been fetched successfully,
+// without waiting for or starting a fetch.
+func (c *listCache) peek(key string) (interface{}, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	select {
+	case <-e.done:
+		return e.value, e.err == nil
+	default:
+		return nil, false
+	}
+}
+
+// invalidate drops the entries for keys, or every entry when no keys are
+// given. Fetches already in flight still deliver their result to the
+// callers waiting on them, but are not reused.
+func (c *listCache) invalidate(keys ...string) {
+	c.mu.Lock()
+	if len(keys) == 0 {
+		c.entries = map[string]*cacheEntry{}
+	}
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+}
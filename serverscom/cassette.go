@@ -0,0 +1,189 @@
+package serverscom
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Environment variables that make NewTransportFromEnv record or replay
+// API traffic.
+const (
+	CassetteEnv     = "SERVERSCOM_CASSETTE"
+	CassetteModeEnv = "SERVERSCOM_CASSETTE_MODE"
+)
+
+// Cassette is a recorded sequence of API calls. Request headers are not
+// kept, and tokens and passwords in bodies are replaced with REDACTED, so
+// cassettes are safe to commit.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Method       string            `json:"method"`
+	Path         string            `json:"path"`
+	RequestBody  string            `json:"request_body,omitempty"`
+	Status       int               `json:"status"`
+	Header       map[string]string `json:"header,omitempty"`
+	ResponseBody string            `json:"response_body"`
+}
+
+// replayedHeaders are the response headers kept in a cassette.
+var replayedHeaders = []string{"Content-Type", "Link", "Retry-After", "X-Request-Id"}
+
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, errors.New(fmt.Sprintf("Cassette %s: %s", path, err))
+	}
+	return &c, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	path      string
+	cassette  Cassette
+	transport http.RoundTripper
+}
+
+// NewRecorder passes requests through to transport and writes each
+// interaction to the cassette file at path as it completes, so a crashed
+// run still leaves a usable cassette. A nil transport means
+// http.DefaultTransport.
+func NewRecorder(path string, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &recorder{path: path, transport: transport}
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Method:       req.Method,
+		Path:         req.URL.RequestURI(),
+		Status:       resp.StatusCode,
+		Header:       map[string]string{},
+		ResponseBody: string(redactBody(resp.Header.Get("Content-Type"), respBody)),
+	}
+	if len(reqBody) > 0 {
+		in.RequestBody = string(redactBody(req.Header.Get("Content-Type"), reqBody))
+	}
+	for _, name := range replayedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			in.Header[name] = v
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	if err = r.cassette.Save(r.path); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer answers requests from a cassette without touching the network.
+// Each request gets the first unused interaction with the same method and
+// path, so repeated calls to one endpoint replay in recorded order. A request
+// with no match fails.
+func NewReplayer(cassette *Cassette) http.RoundTripper {
+	return &replayer{cassette: cassette, used: make([]bool, len(cassette.Interactions))}
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || in.Method != req.Method || in.Path != req.URL.RequestURI() {
+			continue
+		}
+		r.used[i] = true
+		header := http.Header{}
+		for name, v := range in.Header {
+			header.Set(name, v)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(in.ResponseBody))),
+			ContentLength: int64(len(in.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Cassette has no unused interaction for %s %s.", req.Method, req.URL.RequestURI()))
+}
+
+// NewTransportFromEnv wraps transport according to SERVERSCOM_CASSETTE and
+// SERVERSCOM_CASSETTE_MODE: "record" writes the traffic to the cassette file,
+// "replay" serves it from there. Without SERVERSCOM_CASSETTE, transport is
+// returned unchanged.
+func NewTransportFromEnv(transport http.RoundTripper) (http.RoundTripper, error) {
+	path := os.Getenv(CassetteEnv)
+	if path == "" {
+		return transport, nil
+	}
+	switch mode := os.Getenv(CassetteModeEnv); mode {
+	case "record":
+		return NewRecorder(path, transport), nil
+	case "replay", "":
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewReplayer(cassette), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("%s must be record or replay, not %s.", CassetteModeEnv, mode))
+	}
+}
@@ -0,0 +1,155 @@
+package serverscom_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"servers.com/terraform-provider/serverscom"
+	"servers.com/terraform-provider/serverscom/serverscomtest"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "record the testdata cassettes again against serverscomtest")
+
+// fixtureServer is the account the testdata cassettes are recorded from.
+func fixtureServer(t *testing.T) *serverscomtest.Server {
+	fake := serverscomtest.NewServer("user@example.com", "secret")
+	t.Cleanup(fake.Close)
+	web1 := fake.AddHost("web-1", 1)
+	web2 := fake.AddHost("web-2", 1)
+	fake.AddHost("db-1", 2)
+
+	c, err := fake.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.L2Segments.Create(&serverscom.L2Request{
+		Name:       "backend",
+		Type:       1,
+		LocationId: 1,
+		Hosts:      []serverscom.L2HostRequest{{Id: web1.Id, Mode: "native"}, {Id: web2.Id, Mode: "native"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []serverscom.Host{web1, web2} {
+		if _, err = c.DNS.CreatePtr(h.Title+".example.com", h.Networks[0].HostIp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = c.Hosts.Upgrade(web1.Id, &serverscom.ServerUpgrade{RamSize: 64}); err != nil {
+		t.Fatal(err)
+	}
+	order := &serverscom.ServerOrder{
+		Hosts:         []serverscom.OrderHost{{Hostname: "db-2"}},
+		LocationId:    2,
+		ServerModelId: 10,
+		RamSize:       32,
+		OS:            serverscom.OrderOS{Name: "Ubuntu", Version: "18.04", Arch: "x86_64"},
+	}
+	payload, err := order.CartPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Orders.AddToCart(payload); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Orders.Checkout(); err != nil {
+		t.Fatal(err)
+	}
+	// The segment becomes active, the upgrade completes and the new order
+	// stays open with a pending host.
+	fake.Advance()
+	return fake
+}
+
+// record runs call through a recording client logged in to fake and returns
+// the cassette file and the client's token.
+func record(t *testing.T, fake *serverscomtest.Server, path string, call func(*serverscom.Client) (interface{}, error)) (interface{}, string) {
+	c := serverscom.NewClient(fake.URL, fake.Email, fake.Password)
+	c.HTTPClient.Transport = serverscom.NewRecorder(path, nil)
+	if err := c.Login(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := call(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, c.Token()
+}
+
+func replay(t *testing.T, path string, call func(*serverscom.Client) (interface{}, error)) interface{} {
+	cassette, err := serverscom.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := serverscom.NewClient("http://replay.invalid", "user@example.com", "secret")
+	c.HTTPClient.Transport = serverscom.NewReplayer(cassette)
+	if err = c.Login(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := call(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func checkRedacted(t *testing.T, path string, secrets ...string) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "REDACTED") {
+		t.Errorf("%s has no REDACTED values", path)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("%s contains %q", path, secret)
+		}
+	}
+}
+
+// TestRecordCassettes records the list calls against the fake, checks that
+// the cassettes hold no credentials and replays them. With -update the
+// cassettes are written to testdata for the replay tests.
+func TestRecordCassettes(t *testing.T) {
+	fake := fixtureServer(t)
+	dir := t.TempDir()
+	if *update {
+		dir = "testdata"
+	}
+	calls := map[string]func(*serverscom.Client) (interface{}, error){
+		"hosts.json":       func(c *serverscom.Client) (interface{}, error) { return c.Hosts.List() },
+		"l2_segments.json": func(c *serverscom.Client) (interface{}, error) { return c.L2Segments.List() },
+		"dns_records.json": func(c *serverscom.Client) (interface{}, error) { return c.DNS.ListRecords() },
+		"orders.json":      func(c *serverscom.Client) (interface{}, error) { return c.Orders.List() },
+	}
+	for name, call := range calls {
+		path := filepath.Join(dir, name)
+		recorded, token := record(t, fake, path, call)
+		checkRedacted(t, path, fake.Password, token)
+		if replayed := replay(t, path, call); !reflect.DeepEqual(replayed, recorded) {
+			t.Errorf("%s replayed %+v, recorded %+v", name, replayed, recorded)
+		}
+	}
+}
+
+func TestRecorderRedactsReleaseToken(t *testing.T) {
+	fake := fixtureServer(t)
+	path := filepath.Join(t.TempDir(), "release.json")
+	release := func(c *serverscom.Client) (interface{}, error) {
+		host, err := c.Hosts.GetByTitle("db-1")
+		if err != nil {
+			return nil, err
+		}
+		return host.Id, c.Hosts.ScheduleRelease(host.Id)
+	}
+	recorded, token := record(t, fake, path, release)
+	checkRedacted(t, path, fake.Password, token)
+	if replayed := replay(t, path, release); replayed != recorded {
+		t.Errorf("replayed host %v, recorded %v", replayed, recorded)
+	}
+}
@@ -0,0 +1,112 @@
+package serverscom
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// replayClient returns a client that answers from testdata/name, already
+// logged in, and a check that every recorded interaction was replayed. The
+// cassettes are recorded by TestRecordCassettes with -update.
+func replayClient(t *testing.T, name string) (*Client, func()) {
+	cassette, err := LoadCassette(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := NewReplayer(cassette)
+	c := NewClient("https://portal.example.com", "user@example.com", "secret")
+	c.HTTPClient = &http.Client{Transport: transport}
+	if err = c.Login(); err != nil {
+		t.Fatal(err)
+	}
+	return c, func() {
+		for i, used := range transport.(*replayer).used {
+			if !used {
+				in := cassette.Interactions[i]
+				t.Errorf("interaction %d (%s %s) was not replayed", i, in.Method, in.Path)
+			}
+		}
+	}
+}
+
+func TestReplayHostsList(t *testing.T) {
+	c, done := replayClient(t, "hosts.json")
+	defer done()
+	hosts, err := c.Hosts.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, h := range hosts {
+		titles = append(titles, h.Title)
+	}
+	if want := []string{"web-1", "web-2", "db-1"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("titles = %v, want %v", titles, want)
+	}
+	web := hosts[0]
+	if web.Id != 1001 || web.Location.Id != 1 || web.Networks[0].PoolType != "public" || web.Networks[0].HostIp != "198.51.100.2" {
+		t.Fatalf("web-1 = %+v", web)
+	}
+	// A second call is served from the cache; the replayer has nothing left.
+	if _, err = c.Hosts.List(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayL2SegmentsList(t *testing.T) {
+	c, done := replayClient(t, "l2_segments.json")
+	defer done()
+	segments, err := c.L2Segments.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 {
+		t.Fatalf("got %d segments, want 1", len(segments))
+	}
+	if s := segments[0]; s.Name != "backend" || s.Status != "active" || len(s.Hosts) != 2 || s.Hosts[1].Title != "web-2" {
+		t.Fatalf("segment = %+v", s)
+	}
+}
+
+func TestReplayDNSListRecords(t *testing.T) {
+	c, done := replayClient(t, "dns_records.json")
+	defer done()
+	records, err := c.DNS.ListRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if r := records[1]; r.Name != "198.51.100.5" || r.Data != "web-2.example.com" || r.Type != "PTR" {
+		t.Fatalf("second record = %+v", r)
+	}
+}
+
+func TestReplayOrdersList(t *testing.T) {
+	c, done := replayClient(t, "orders.json")
+	defer done()
+	orders, err := c.Orders.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("got %d orders, want 2", len(orders))
+	}
+	if o := orders[0]; o.Status != OrderStatusCompleted || o.IsOpen() || o.Description[0] != "Upgrade of web-1" {
+		t.Fatalf("first order = %+v", o)
+	}
+	if o := orders[1]; !o.IsOpen() || o.Description[0] != "db-2" {
+		t.Fatalf("second order = %+v", o)
+	}
+}
+
+func TestReplayUnmatchedRequest(t *testing.T) {
+	c, _ := replayClient(t, "orders.json")
+	c.MaxRetries = 0
+	if _, err := c.L2Segments.List(); err == nil {
+		t.Fatal("expected an error for a request the cassette does not have")
+	}
+}
@@ -0,0 +1,234 @@
+// Package serverscom is a client for the Servers.com customer portal REST API.
+// It has no Terraform dependencies and can be used on its own.
+package serverscom
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = 60 * time.Second
+
+type Client struct {
+	BaseURL    string
+	Email      string
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a request that failed with a transient
+	// error is repeated; MaxRetryWait caps the pause before each repeat.
+	MaxRetries   int
+	MaxRetryWait time.Duration
+
+	Hosts      HostsService
+	Orders     OrdersService
+	L2Segments L2SegmentsService
+	DNS        DNSService
+
+	password string
+	limiter  *rateLimiter
+	cache    *listCache
+
+	// tokenMu guards token, which is replaced when it expires mid-run.
+	tokenMu sync.Mutex
+	token   string
+}
+
+func NewClient(baseURL, email, password string) *Client {
+	c := &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		Email:        email,
+		HTTPClient:   &http.Client{Timeout: defaultTimeout},
+		MaxRetries:   DefaultMaxRetries,
+		MaxRetryWait: DefaultMaxRetryWait,
+		password:     password,
+		cache:        newListCache(),
+	}
+	c.Hosts = &hostsService{client: c}
+	c.Orders = &ordersService{client: c}
+	c.L2Segments = &l2SegmentsService{client: c}
+	c.DNS = &dnsService{client: c}
+	return c
+}
+
+type tokenResp struct {
+	Token string `json:"token"`
+}
+
+// Login exchanges the email and password for an API token.
+func (c *Client) Login() error {
+	t, err := c.getToken()
+	if err != nil {
+		return err
+	}
+	c.tokenMu.Lock()
+	c.token = t
+	c.tokenMu.Unlock()
+	return nil
+}
+
+// SetToken makes the client use an existing API token instead of logging in.
+// Without a password the client cannot renew the token when it expires.
+func (c *Client) SetToken(token string) {
+	c.tokenMu.Lock()
+	c.token = token
+	c.tokenMu.Unlock()
+}
+
+// SetRateLimit caps the client at rps requests per second across all
+// goroutines, retries and logins included. Zero or less removes the cap.
+// It is meant to be called once, before the client is used.
+func (c *Client) SetRateLimit(rps float64) {
+	if rps <= 0 {
+		c.limiter = nil
+		return
+	}
+	c.limiter = newRateLimiter(rps)
+}
+
+// InvalidateCache makes the next list calls for keys, or for every list when
+// no keys are given, fetch fresh data. Callers that poll for a change made
+// outside the client need it; changes made through the client invalidate
+// the cache themselves.
+func (c *Client) InvalidateCache(keys ...string) {
+	c.cache.invalidate(keys...)
+}
+
+func (c *Client) Token() string {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.token
+}
+
+// relogin fetches a new token after a request sent with stale was rejected.
+// Concurrent callers that saw the same expired token wait for a single login
+// and then share its result.
+func (c *Client) relogin(stale string) error {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.token != stale {
+		return nil
+	}
+	log.Printf("[DEBUG] serverscom: token rejected, logging in again as %s", c.Email)
+	t, err := c.getToken()
+	if err != nil {
+		return err
+	}
+	c.token = t
+	return nil
+}
+
+func (c *Client) getToken() (string, error) {
+	form := neturl.Values{}
+	form.Add("email", c.Email)
+	form.Add("pwd", c.password)
+	resp, b, err := c.do(true, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", c.BaseURL+"/p/login_token", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", newAPIError("POST", "/p/login_token", resp, b)
+	}
+	var t tokenResp
+	if err = json.Unmarshal(b, &t); err != nil {
+		return "", err
+	}
+	return t.Token, nil
+}
+
+func (c *Client) getResponse(method, path string, data io.Reader) ([]byte, error) {
+	_, b, err := c.request(method, path, data)
+	return b, err
+}
+
+// request is getResponse for callers that also need the response headers.
+func (c *Client) request(method, path string, data io.Reader) (*http.Response, []byte, error) {
+	var payload []byte
+	if data != nil {
+		var err error
+		if payload, err = ioutil.ReadAll(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	token := c.Token()
+	newRequest := func() (*http.Request, error) {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequest(method, c.BaseURL+path, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("X-User-Email", c.Email)
+		req.Header.Set("X-User-Token", token)
+		return req, nil
+	}
+	if method != "GET" {
+		defer c.cache.invalidate()
+	}
+	resp, b, err := c.do(isIdempotent(method), newRequest)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && c.password != "" {
+		// The token has expired: log in again and replay the request once.
+		// The API rejected the first attempt outright, so this is safe even
+		// for requests that are not idempotent.
+		if err = c.relogin(token); err != nil {
+			return nil, nil, err
+		}
+		token = c.Token()
+		resp, b, err = c.do(isIdempotent(method), newRequest)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		return resp, b, nil
+	}
+	return resp, b, newAPIError(method, path, resp, b)
+}
+
+// do sends the request built by newRequest, building and sending it again
+// while the attempt fails in a way shouldRetry considers transient. The
+// response body is read and closed before returning.
+func (c *Client) do(idempotent bool, newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.limiter != nil {
+			c.limiter.Wait()
+		}
+		var b []byte
+		resp, err := c.HTTPClient.Do(req)
+		if err == nil {
+			b, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if attempt > c.MaxRetries || !shouldRetry(idempotent, resp, err) {
+			return resp, b, err
+		}
+		wait := retryWait(attempt, c.MaxRetryWait, resp)
+		if err != nil {
+			log.Printf("[DEBUG] serverscom: %s %s failed: %s, retry %d in %s", req.Method, req.URL.Path, err, attempt, wait)
+		} else {
+			log.Printf("[DEBUG] serverscom: %s %s returned %d, retry %d in %s", req.Method, req.URL.Path, resp.StatusCode, attempt, wait)
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,88 @@
+package serverscom_test
+
+import (
+	"errors"
+	"net/http"
+	"servers.com/terraform-provider/serverscom"
+	"servers.com/terraform-provider/serverscom/serverscomtest"
+	"testing"
+)
+
+func newTestClient(t *testing.T) (*serverscomtest.Server, *serverscom.Client) {
+	fake := serverscomtest.NewServer("user@example.com", "secret")
+	t.Cleanup(fake.Close)
+	fake.AddHost("web-1", 1)
+	c, err := fake.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, c
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	fake, c := newTestClient(t)
+	fake.FailNext(http.StatusBadGateway, http.StatusServiceUnavailable)
+	hosts, err := c.Hosts.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Title != "web-1" {
+		t.Fatalf("hosts = %+v", hosts)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	fake, c := newTestClient(t)
+	c.MaxRetries = 1
+	fake.FailNext(http.StatusInternalServerError, http.StatusInternalServerError)
+	_, err := c.Hosts.List()
+	var apiErr *serverscom.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("err = %v, want a 500 APIError", err)
+	}
+}
+
+func TestNoRetryForPost(t *testing.T) {
+	fake, c := newTestClient(t)
+	fake.FailNext(http.StatusServiceUnavailable)
+	if _, err := c.DNS.CreatePtr("web-1.example.com", "198.51.100.10"); err == nil {
+		t.Fatal("expected the 503 to be returned")
+	}
+	records, err := c.DNS.ListRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("the POST was sent again: %+v", records)
+	}
+}
+
+func TestRetryTooManyRequestsForPost(t *testing.T) {
+	fake, c := newTestClient(t)
+	fake.FailNext(http.StatusTooManyRequests)
+	if _, err := c.DNS.CreatePtr("web-1.example.com", "198.51.100.10"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloginAfterTokenExpiry(t *testing.T) {
+	fake, c := newTestClient(t)
+	stale := c.Token()
+	fake.ExpireTokens()
+	if _, err := c.Hosts.List(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token() == stale {
+		t.Fatal("token was not renewed")
+	}
+}
+
+func TestExpiredTokenWithoutPassword(t *testing.T) {
+	fake, c := newTestClient(t)
+	tokenOnly := serverscom.NewClient(fake.URL, fake.Email, "")
+	tokenOnly.SetToken(c.Token())
+	fake.ExpireTokens()
+	if _, err := tokenOnly.Hosts.List(); !serverscom.IsUnauthorized(err) {
+		t.Fatalf("err = %v, want a 401", err)
+	}
+}
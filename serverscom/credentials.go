@@ -0,0 +1,90 @@
+package serverscom
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const DefaultProfile = "default"
+
+// Credentials is one profile of a credentials file. Unset fields are empty.
+type Credentials struct {
+	URL      string
+	Email    string
+	Password string
+	Token    string
+}
+
+// DefaultCredentialsFile returns ~/.serverscom/credentials, or an empty string
+// when the home directory is unknown.
+func DefaultCredentialsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".serverscom", "credentials")
+}
+
+// LoadCredentials reads the named profile from an INI-style credentials file:
+//
+//	[default]
+//	url   = https://portal.servers.com
+//	email = user@example.com
+//	token = ...
+//
+// Errors for a missing file satisfy os.IsNotExist.
+func LoadCredentials(path, profile string) (*Credentials, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var creds *Credentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == profile {
+				creds = &Credentials{}
+			}
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New(fmt.Sprintf("%s:%d: expected key = value.", path, n))
+		}
+		value := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "url":
+			creds.URL = value
+		case "email":
+			creds.Email = value
+		case "password":
+			creds.Password = value
+		case "token":
+			creds.Token = value
+		default:
+			return nil, errors.New(fmt.Sprintf("%s:%d: unknown key %q.", path, n, strings.TrimSpace(kv[0])))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return nil, errors.New(fmt.Sprintf("Profile %s not found in %s.", profile, path))
+	}
+	return creds, nil
+}
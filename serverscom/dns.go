@@ -0,0 +1,111 @@
+package serverscom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type DNSService interface {
+	ListRecords() ([]Ptr, error)
+	EachRecord(fn func(Ptr) bool) error
+	GetRecord(id string) (*Ptr, error)
+	CreatePtr(data, name string) (*Ptr, error)
+	DeleteRecord(id string, domainId int) error
+}
+
+type ptrData struct {
+	Data Ptr `json:"data"`
+}
+
+type Ptr struct {
+	Id       int         `json:"id"`
+	DomainId int         `json:"domain_id"`
+	Type     string      `json:"type"`
+	Name     string      `json:"name"`
+	Ttl      int         `json:"ttl"`
+	Priority int         `json:"priority"`
+	Data     interface{} `json:"data"`
+	Disabled bool        `json:"disabled"`
+}
+
+type dnsService struct {
+	client *Client
+}
+
+func (s *dnsService) ListRecords() ([]Ptr, error) {
+	v, err := s.client.cache.get(CacheDNSRecords, func() (interface{}, error) {
+		items := []Ptr{}
+		err := s.pages(func(item Ptr) bool {
+			items = append(items, item)
+			return true
+		})
+		return items, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]Ptr(nil), v.([]Ptr)...), nil
+}
+
+// EachRecord calls fn for every DNS record until it returns false.
+func (s *dnsService) EachRecord(fn func(Ptr) bool) error {
+	if v, ok := s.client.cache.peek(CacheDNSRecords); ok {
+		for _, item := range v.([]Ptr) {
+			if !fn(item) {
+				return nil
+			}
+		}
+		return nil
+	}
+	return s.pages(fn)
+}
+
+func (s *dnsService) pages(fn func(Ptr) bool) error {
+	return s.client.eachPage("/rest/dns/records//", func(data json.RawMessage) (bool, error) {
+		var items []Ptr
+		if err := json.Unmarshal(data, &items); err != nil {
+			return false, err
+		}
+		for _, item := range items {
+			if !fn(item) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// GetRecord returns nil without an error when the record does not exist.
+func (s *dnsService) GetRecord(id string) (*Ptr, error) {
+	ptrs, err := s.ListRecords()
+	if err != nil {
+		return nil, err
+	}
+	for _, ptr := range ptrs {
+		if fmt.Sprintf("%d", ptr.Id) == id {
+			return &ptr, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreatePtr points the reverse record of the IP address name at data.
+func (s *dnsService) CreatePtr(data, name string) (*Ptr, error) {
+	body, err := s.client.getResponse("POST", "/rest/dns/records//",
+		strings.NewReader(fmt.Sprintf(`{"data":"%s","name":"%s"}`, data, name)))
+	if err != nil {
+		return nil, err
+	}
+	var ptr ptrData
+	if err = json.Unmarshal(body, &ptr); err != nil {
+		return nil, err
+	}
+	return &ptr.Data, nil
+}
+
+func (s *dnsService) DeleteRecord(id string, domainId int) error {
+	_, err := s.client.getResponse("DELETE", fmt.Sprintf("/rest/dns/records///%s", id),
+		strings.NewReader(fmt.Sprintf(`{"domain_id":%d}`, domainId)))
+	return err
+}
@@ -0,0 +1,136 @@
+package serverscom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// APIError is returned for every response with an unexpected status code.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	RequestID  string
+
+	// Message is the top-level error text of the response, if any, and
+	// FieldErrors maps request fields to their validation messages.
+	Message     string
+	FieldErrors map[string][]string
+
+	Body []byte
+}
+
+func newAPIError(method, path string, resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     method,
+		Path:       path,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		Body:       body,
+	}
+	e.parseBody()
+	return e
+}
+
+// parseBody understands the error shapes the portal uses:
+//
+//	{"error": "text"}
+//	{"message": "text", "errors": {"field": ["text", ...]}}
+//	{"errors": ["text", ...]}
+//
+// and leaves the raw body for anything else.
+func (e *APIError) parseBody() {
+	var payload map[string]json.RawMessage
+	if json.Unmarshal(e.Body, &payload) != nil {
+		return
+	}
+	for _, key := range []string{"message", "error"} {
+		var s string
+		if raw, ok := payload[key]; ok && json.Unmarshal(raw, &s) == nil && s != "" {
+			e.Message = s
+			break
+		}
+	}
+	raw, ok := payload["errors"]
+	if !ok {
+		return
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		if e.Message == "" {
+			e.Message = strings.Join(list, "; ")
+		}
+		return
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		return
+	}
+	e.FieldErrors = map[string][]string{}
+	for field, v := range fields {
+		var msgs []string
+		var msg string
+		if json.Unmarshal(v, &msgs) == nil {
+			e.FieldErrors[field] = msgs
+		} else if json.Unmarshal(v, &msg) == nil {
+			e.FieldErrors[field] = []string{msg}
+		} else {
+			e.FieldErrors[field] = []string{string(v)}
+		}
+	}
+}
+
+// Fields returns the names of the fields that failed validation, sorted.
+func (e *APIError) Fields() []string {
+	fields := make([]string, 0, len(e.FieldErrors))
+	for field := range e.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	for _, field := range e.Fields() {
+		fmt.Fprintf(&b, "; %s: %s", field, strings.Join(e.FieldErrors[field], ", "))
+	}
+	if e.Message == "" && len(e.FieldErrors) == 0 && len(e.Body) > 0 {
+		fmt.Fprintf(&b, ": %s", string(e.Body))
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request id %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsValidation reports whether the API rejected the request body, in which
+// case FieldErrors usually says which fields were wrong.
+func IsValidation(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity) || hasStatus(err, http.StatusBadRequest)
+}
@@ -0,0 +1,232 @@
+package serverscom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type HostsService interface {
+	List() ([]Host, error)
+	ListPending() ([]Host, error)
+	Each(fn func(Host) bool) error
+	EachPending(fn func(Host) bool) error
+	Get(id int) (*Host, error)
+	GetByTitle(title string) (*Host, error)
+	GetConfiguration(id int) (*HostConfiguration, error)
+	ScheduleRelease(id int) error
+	CancelPending(id int) error
+	UpdateTitle(id int, title string) (*Host, error)
+	Upgrade(id int, upgrade *ServerUpgrade) (*Order, error)
+	Reinstall(id int, os *OrderOS) error
+}
+
+type Network struct {
+	Id       int    `json:"id"`
+	HostIp   string `json:"host_ip"`
+	PoolType string `json:"pool_type"`
+	Size     int    `json:"size"`
+	Netmask  string `json:"netmask"`
+}
+
+type Location struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Host struct {
+	Id                 int         `json:"id"`
+	Type               int         `json:"type"`
+	Title              string      `json:"title"`
+	Conf               string      `json:"conf"`
+	ServiceType        int         `json:"service_type"`
+	LeaseEnd           interface{} `json:"lease_end"`
+	Networks           []Network   `json:"networks"`
+	Location           Location    `json:"location"`
+	ProjectId          interface{} `json:"project_id"`
+	ProjectName        interface{} `json:"project_name"`
+	ScheduledReleaseAt interface{} `json:"scheduled_release_at"`
+	RackName           interface{} `json:"rack_name"`
+	RackId             interface{} `json:"rack_id"`
+	L2Segments         interface{} `json:"l2_segments"`
+}
+
+type hostData struct {
+	Data *Host `json:"data"`
+}
+
+type hostConfigurationData struct {
+	Data *HostConfiguration `json:"data"`
+}
+
+type hostsService struct {
+	client *Client
+}
+
+func (s *hostsService) List() ([]Host, error) {
+	return s.list(CacheHosts, "/rest/hosts")
+}
+
+func (s *hostsService) ListPending() ([]Host, error) {
+	return s.list(CachePendingHosts, "/rest/hosts_pending")
+}
+
+// Each calls fn for every active host until it returns false.
+func (s *hostsService) Each(fn func(Host) bool) error {
+	return s.each(CacheHosts, "/rest/hosts", fn)
+}
+
+// EachPending calls fn for every pending host until it returns false.
+func (s *hostsService) EachPending(fn func(Host) bool) error {
+	return s.each(CachePendingHosts, "/rest/hosts_pending", fn)
+}
+
+func (s *hostsService) list(key, path string) ([]Host, error) {
+	v, err := s.client.cache.get(key, func() (interface{}, error) {
+		hosts := []Host{}
+		err := s.pages(path, func(h Host) bool {
+			hosts = append(hosts, h)
+			return true
+		})
+		return hosts, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]Host(nil), v.([]Host)...), nil
+}
+
+// each walks the cached list when there is one, and otherwise streams pages
+// without caching them, so that stopping early saves requests.
+func (s *hostsService) each(key, path string, fn func(Host) bool) error {
+	if v, ok := s.client.cache.peek(key); ok {
+		for _, h := range v.([]Host) {
+			if !fn(h) {
+				return nil
+			}
+		}
+		return nil
+	}
+	return s.pages(path, fn)
+}
+
+func (s *hostsService) pages(path string, fn func(Host) bool) error {
+	return s.client.eachPage(path, func(data json.RawMessage) (bool, error) {
+		var hosts []Host
+		if err := json.Unmarshal(data, &hosts); err != nil {
+			return false, err
+		}
+		for _, h := range hosts {
+			if !fn(h) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// Get returns nil without an error when there is no active host with the id.
+func (s *hostsService) Get(id int) (*Host, error) {
+	hosts, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		if hosts[i].Id == id {
+			return &hosts[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// GetByTitle returns nil without an error when no host has the given title.
+// It scans the cached host list, so looking up many hosts costs one request.
+func (s *hostsService) GetByTitle(title string) (*Host, error) {
+	hosts, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var found *Host
+	for i := range hosts {
+		if hosts[i].Title != title {
+			continue
+		}
+		if found != nil {
+			return nil, errors.New(fmt.Sprintf("Hostname: %s is not unique.", title))
+		}
+		found = &hosts[i]
+	}
+	return found, nil
+}
+
+// ScheduleRelease confirms the release with the account password, so it
+// fails for clients set up with only an API token.
+func (s *hostsService) ScheduleRelease(id int) error {
+	if s.client.password == "" {
+		return errors.New("Releasing a server requires the account password; it cannot be done with only an API token.")
+	}
+	data := strings.NewReader(fmt.Sprintf("{\"token\":\"%s\"}", s.client.password))
+	_, err := s.client.getResponse("POST", fmt.Sprintf("/rest/hosts/%d/schedule_release", id), data)
+	return err
+}
+
+func (s *hostsService) GetConfiguration(id int) (*HostConfiguration, error) {
+	body, err := s.client.getResponse("GET", fmt.Sprintf("/rest/hosts/%d/configuration", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	var config hostConfigurationData
+	if err = json.Unmarshal(body, &config); err != nil {
+		return nil, err
+	}
+	return config.Data, nil
+}
+
+// CancelPending cancels a server that has been set up but not handed over
+// yet, so it never shows up among the active hosts.
+func (s *hostsService) CancelPending(id int) error {
+	_, err := s.client.getResponse("POST", fmt.Sprintf("/rest/hosts_pending/%d/cancel", id), nil)
+	return err
+}
+
+func (s *hostsService) UpdateTitle(id int, title string) (*Host, error) {
+	data, err := json.Marshal(map[string]string{"title": title})
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.client.getResponse("PUT", fmt.Sprintf("/rest/hosts/%d", id), strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	var h hostData
+	if err = json.Unmarshal(body, &h); err != nil {
+		return nil, err
+	}
+	return h.Data, nil
+}
+
+func (s *hostsService) Upgrade(id int, upgrade *ServerUpgrade) (*Order, error) {
+	data, err := json.Marshal(map[string]*ServerUpgrade{"data": upgrade})
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.client.getResponse("POST", fmt.Sprintf("/rest/hosts/%d/upgrade", id), strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	var order orderData
+	if err = json.Unmarshal(body, &order); err != nil {
+		return nil, err
+	}
+	return &order.Data, nil
+}
+
+func (s *hostsService) Reinstall(id int, os *OrderOS) error {
+	data, err := json.Marshal(map[string]*OrderOS{"os": os})
+	if err != nil {
+		return err
+	}
+	_, err = s.client.getResponse("POST", fmt.Sprintf("/rest/hosts/%d/reinstall", id), strings.NewReader(string(data)))
+	return err
+}
@@ -0,0 +1,150 @@
+package serverscom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type L2SegmentsService interface {
+	List() ([]L2Segment, error)
+	Each(fn func(L2Segment) bool) error
+	Get(id string) (*L2Segment, error)
+	Create(req *L2Request) (*L2Segment, error)
+	Update(id string, req *L2Request) (*L2Segment, error)
+	Delete(id string) (bool, error)
+}
+
+type l2SegmentData struct {
+	Data *L2Segment `json:"data"`
+}
+
+type L2Segment struct {
+	Id       int         `json:"id"`
+	Hosts    []L2Host    `json:"hosts"`
+	Location interface{} `json:"location"`
+	Name     string      `json:"name"`
+	Status   string      `json:"status"`
+	Type     int         `json:"type"`
+}
+
+type L2Host struct {
+	Id    int         `json:"id"`
+	Mode  string      `json:"mode"`
+	Title string      `json:"title"`
+	Vlan  interface{} `json:"vlan"`
+}
+
+type L2Request struct {
+	DeleteIps  interface{}     `json:"delete_ips"`
+	Hosts      []L2HostRequest `json:"hosts"`
+	LocationId int             `json:"location_id"`
+	Name       string          `json:"name"`
+	Type       int             `json:"type"`
+}
+
+type L2HostRequest struct {
+	Id   int    `json:"id"`
+	Mode string `json:"mode"`
+}
+
+type successResp struct {
+	Success bool `json:"success"`
+}
+
+type l2SegmentsService struct {
+	client *Client
+}
+
+func (s *l2SegmentsService) List() ([]L2Segment, error) {
+	v, err := s.client.cache.get(CacheL2Segments, func() (interface{}, error) {
+		items := []L2Segment{}
+		err := s.pages(func(item L2Segment) bool {
+			items = append(items, item)
+			return true
+		})
+		return items, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]L2Segment(nil), v.([]L2Segment)...), nil
+}
+
+// Each calls fn for every L2 segment until it returns false.
+func (s *l2SegmentsService) Each(fn func(L2Segment) bool) error {
+	if v, ok := s.client.cache.peek(CacheL2Segments); ok {
+		for _, item := range v.([]L2Segment) {
+			if !fn(item) {
+				return nil
+			}
+		}
+		return nil
+	}
+	return s.pages(fn)
+}
+
+func (s *l2SegmentsService) pages(fn func(L2Segment) bool) error {
+	return s.client.eachPage("/rest/l2_segments", func(data json.RawMessage) (bool, error) {
+		var items []L2Segment
+		if err := json.Unmarshal(data, &items); err != nil {
+			return false, err
+		}
+		for _, item := range items {
+			if !fn(item) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// Get returns nil without an error when the segment does not exist.
+func (s *l2SegmentsService) Get(id string) (*L2Segment, error) {
+	segments, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, l2 := range segments {
+		if fmt.Sprintf("%d", l2.Id) == id {
+			return &l2, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *l2SegmentsService) Create(req *L2Request) (*L2Segment, error) {
+	return s.send("POST", "/rest/l2_segments/", req)
+}
+
+func (s *l2SegmentsService) Update(id string, req *L2Request) (*L2Segment, error) {
+	return s.send("PUT", fmt.Sprintf("/rest/l2_segments/%s", id), req)
+}
+
+func (s *l2SegmentsService) send(method, path string, req *L2Request) (*L2Segment, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.client.getResponse(method, path, strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	var l2Data l2SegmentData
+	if err = json.Unmarshal(body, &l2Data); err != nil {
+		return nil, err
+	}
+	return l2Data.Data, nil
+}
+
+func (s *l2SegmentsService) Delete(id string) (bool, error) {
+	body, err := s.client.getResponse("DELETE", fmt.Sprintf("/rest/l2_segments/%s/", id), nil)
+	if err != nil {
+		return false, err
+	}
+	var success successResp
+	if err = json.Unmarshal(body, &success); err != nil {
+		return false, nil
+	}
+	return success.Success, nil
+}
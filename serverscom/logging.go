@@ -0,0 +1,147 @@
+package serverscom
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+// secretHeaders and secretFields are never written to the log.
+var (
+	secretHeaders = []string{"X-User-Token", "Authorization", "Cookie", "Set-Cookie"}
+	secretFields  = map[string]bool{"token": true, "pwd": true, "password": true}
+)
+
+type loggingTransport struct {
+	transport http.RoundTripper
+	logBodies bool
+}
+
+// NewLoggingTransport logs every request and response through the standard
+// log package, [DEBUG] lines for methods, URLs, statuses and headers, and
+// [TRACE] lines for bodies when logBodies is set. Tokens and passwords are
+// replaced with REDACTED. A nil transport means http.DefaultTransport.
+func NewLoggingTransport(transport http.RoundTripper, logBodies bool) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &loggingTransport{transport: transport, logBodies: logBodies}
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log.Printf("[DEBUG] serverscom: request %s %s\n%s", req.Method, req.URL.RequestURI(), formatHeaders(req.Header))
+	if t.logBodies && req.Body != nil {
+		body, err := t.requestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[TRACE] serverscom: request body: %s", redactBody(req.Header.Get("Content-Type"), body))
+	}
+
+	start := time.Now()
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		log.Printf("[DEBUG] serverscom: %s %s failed after %s: %s", req.Method, req.URL.RequestURI(), time.Since(start), err)
+		return nil, err
+	}
+	log.Printf("[DEBUG] serverscom: response %s %s: %s in %s\n%s", req.Method, req.URL.RequestURI(),
+		resp.Status, time.Since(start), formatHeaders(resp.Header))
+	if t.logBodies {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		log.Printf("[TRACE] serverscom: response body: %s", redactBody(resp.Header.Get("Content-Type"), body))
+	}
+	return resp, nil
+}
+
+// requestBody returns a copy of the request body, leaving the original
+// readable for the wrapped transport.
+func (t *loggingTransport) requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func formatHeaders(h http.Header) string {
+	h = redactHeaders(h)
+	var b strings.Builder
+	h.Write(&b)
+	return strings.TrimRight(b.String(), "\r\n")
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range secretHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, redacted)
+		}
+	}
+	return out
+}
+
+// redactBody masks secret fields in form-encoded and JSON bodies. Bodies in
+// any other format are returned unchanged.
+func redactBody(contentType string, body []byte) []byte {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		form, err := neturl.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		for key := range form {
+			if secretFields[key] {
+				form.Set(key, redacted)
+			}
+		}
+		return []byte(form.Encode())
+	}
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if secretFields[key] {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+	return v
+}
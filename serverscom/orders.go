@@ -0,0 +1,183 @@
+package serverscom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type OrdersService interface {
+	List() ([]Order, error)
+	Get(id int) (*Order, error)
+	Each(fn func(Order) bool) error
+	ListCart() ([]CartItem, error)
+	AddToCart(config string) (*CartItem, error)
+	RemoveFromCart(id int) error
+	Checkout() (*Order, error)
+	Cancel(id int) (*Order, error)
+}
+
+// Orders in any status other than these are still being worked on and may
+// turn into hosts.
+const (
+	OrderStatusCompleted = 2
+	OrderStatusCancelled = 3
+)
+
+type orderData struct {
+	Data Order `json:"data"`
+}
+
+type CartItem struct {
+	Id int `json:"id"`
+}
+
+type cartItemData struct {
+	Data CartItem `json:"data"`
+}
+
+type Order struct {
+	Amount           float64  `json:"amount"`
+	AmountTax        float64  `json:"amount_tax"`
+	AmountTotal      float64  `json:"amount_total"`
+	CreatedTime      string   `json:"created_time"`
+	Currency         string   `json:"currency"`
+	Description      []string `json:"description"`
+	Id               int      `json:"id"`
+	OriginalAmount   float64  `json:"original_amount"`
+	OriginalCurrency string   `json:"original_currency"`
+	Status           int      `json:"status"`
+}
+
+type ordersService struct {
+	client *Client
+}
+
+func (s *ordersService) List() ([]Order, error) {
+	v, err := s.client.cache.get(CacheOrders, func() (interface{}, error) {
+		items := []Order{}
+		err := s.pages(func(item Order) bool {
+			items = append(items, item)
+			return true
+		})
+		return items, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]Order(nil), v.([]Order)...), nil
+}
+
+// Get returns nil without an error when the order does not exist.
+func (s *ordersService) Get(id int) (*Order, error) {
+	orders, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		if orders[i].Id == id {
+			return &orders[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Each calls fn for every order until it returns false.
+func (s *ordersService) Each(fn func(Order) bool) error {
+	if v, ok := s.client.cache.peek(CacheOrders); ok {
+		for _, item := range v.([]Order) {
+			if !fn(item) {
+				return nil
+			}
+		}
+		return nil
+	}
+	return s.pages(fn)
+}
+
+func (s *ordersService) pages(fn func(Order) bool) error {
+	return s.client.eachPage("/rest/orders", func(data json.RawMessage) (bool, error) {
+		var items []Order
+		if err := json.Unmarshal(data, &items); err != nil {
+			return false, err
+		}
+		for _, item := range items {
+			if !fn(item) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// ListCart returns what is in the account's shopping cart right now. It is
+// never cached, since anyone with portal access can change the cart.
+func (s *ordersService) ListCart() ([]CartItem, error) {
+	items := []CartItem{}
+	err := s.client.eachPage("/rest/server_cart_items", func(data json.RawMessage) (bool, error) {
+		var page []CartItem
+		if err := json.Unmarshal(data, &page); err != nil {
+			return false, err
+		}
+		items = append(items, page...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AddToCart puts a server configuration, already rendered as the JSON cart
+// payload, into the account's shopping cart.
+func (s *ordersService) AddToCart(config string) (*CartItem, error) {
+	body, err := s.client.getResponse("POST", "/rest/server_cart_items", strings.NewReader(config))
+	if err != nil {
+		return nil, err
+	}
+	var item cartItemData
+	if err = json.Unmarshal(body, &item); err != nil {
+		return nil, err
+	}
+	return &item.Data, nil
+}
+
+func (s *ordersService) RemoveFromCart(id int) error {
+	_, err := s.client.getResponse("DELETE", fmt.Sprintf("/rest/server_cart_items/%d", id), nil)
+	return err
+}
+
+// Checkout orders everything in the cart and returns the new order. Callers
+// that share the account with people using the portal should check the cart
+// with ListCart first.
+func (s *ordersService) Checkout() (*Order, error) {
+	data := strings.NewReader("{\"ts\":1456817777230}")
+	body, err := s.client.getResponse("POST", "/rest/orders", data)
+	if err != nil {
+		return nil, err
+	}
+	var order orderData
+	if err = json.Unmarshal(body, &order); err != nil {
+		return nil, err
+	}
+	return &order.Data, nil
+}
+
+// IsOpen reports whether the order may still turn into hosts.
+func (o *Order) IsOpen() bool {
+	return o.Status != OrderStatusCompleted && o.Status != OrderStatusCancelled
+}
+
+// Cancel asks for an unfulfilled order to be cancelled. The order reaches
+// OrderStatusCancelled once the portal has stopped working on it.
+func (s *ordersService) Cancel(id int) (*Order, error) {
+	body, err := s.client.getResponse("POST", fmt.Sprintf("/rest/orders/%d/cancel", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	var order orderData
+	if err = json.Unmarshal(body, &order); err != nil {
+		return nil, err
+	}
+	return &order.Data, nil
+}
@@ -0,0 +1,108 @@
+package serverscom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// PageSize is the per_page value asked for when listing.
+const PageSize = 100
+
+type page struct {
+	Data json.RawMessage `json:"data"`
+	Meta *pageMeta       `json:"meta"`
+}
+
+type pageMeta struct {
+	CurrentPage int `json:"current_page"`
+	LastPage    int `json:"last_page"`
+	TotalPages  int `json:"total_pages"`
+}
+
+// eachPage GETs path and the pages after it, calling fn with the data array
+// of each until fn returns false. The next page is taken from a rel="next"
+// Link header, or from page counters in the response meta object. Responses
+// with neither are a single page.
+func (c *Client) eachPage(path string, fn func(data json.RawMessage) (bool, error)) error {
+	seen := map[string]bool{}
+	next := withPage(path, 1)
+	for next != "" {
+		if seen[next] {
+			return errors.New(fmt.Sprintf("Pagination loop at %s.", next))
+		}
+		seen[next] = true
+
+		resp, body, err := c.request("GET", next, nil)
+		if err != nil {
+			return err
+		}
+		var p page
+		if err = json.Unmarshal(body, &p); err != nil {
+			return err
+		}
+		more, err := fn(p.Data)
+		if err != nil || !more {
+			return err
+		}
+		next = c.nextPage(path, resp, p.Meta)
+	}
+	return nil
+}
+
+func (c *Client) nextPage(path string, resp *http.Response, meta *pageMeta) string {
+	if link := nextLink(resp.Header); link != "" {
+		return c.relativePath(link)
+	}
+	if meta == nil || meta.CurrentPage == 0 {
+		return ""
+	}
+	last := meta.LastPage
+	if last == 0 {
+		last = meta.TotalPages
+	}
+	if meta.CurrentPage >= last {
+		return ""
+	}
+	return withPage(path, meta.CurrentPage+1)
+}
+
+// relativePath turns a link from the API into a path below BaseURL.
+func (c *Client) relativePath(link string) string {
+	if strings.HasPrefix(link, c.BaseURL) {
+		return strings.TrimPrefix(link, c.BaseURL)
+	}
+	u, err := neturl.Parse(link)
+	if err != nil {
+		return link
+	}
+	return u.RequestURI()
+}
+
+func withPage(path string, n int) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%spage=%d&per_page=%d", path, sep, n, PageSize)
+}
+
+// nextLink extracts the rel="next" target of an RFC 8288 Link header.
+func nextLink(h http.Header) string {
+	for _, value := range h["Link"] {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			for _, param := range parts[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+				if param == `rel="next"` || param == "rel=next" {
+					return target
+				}
+			}
+		}
+	}
+	return ""
+}
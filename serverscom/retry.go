@@ -0,0 +1,85 @@
+package serverscom
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultMaxRetries   = 3
+	DefaultMaxRetryWait = 30 * time.Second
+
+	minRetryWait = 1 * time.Second
+)
+
+// isIdempotent reports whether a request can be sent again after a failure
+// whose outcome on the server is unknown. POST requests place orders, add
+// cart items and create records, so they are never replayed in that case.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// shouldRetry decides whether an attempt that ended with resp or err is worth
+// repeating. A 429 is always safe to repeat because the API rejected the
+// request before doing anything with it.
+func shouldRetry(idempotent bool, resp *http.Response, err error) bool {
+	if err != nil {
+		return idempotent && isTransientError(err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return idempotent && resp.StatusCode >= 500
+}
+
+func isTransientError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryWait returns how long to sleep before the given retry attempt
+// (starting at 1): exponential backoff with jitter, stretched to honor a
+// Retry-After header and capped at maxWait.
+func retryWait(attempt int, maxWait time.Duration, resp *http.Response) time.Duration {
+	wait := minRetryWait << uint(attempt-1)
+	if wait <= 0 || wait > maxWait {
+		wait = maxWait
+	}
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	if resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && after > wait {
+			wait = after
+		}
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+	return wait
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
@@ -0,0 +1,110 @@
+package serverscom
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ServerOrder is the configuration of a server as put into the cart.
+type ServerOrder struct {
+	Hosts           []OrderHost             `json:"hosts"`
+	LocationId      int                     `json:"location_id"`
+	ServerModelId   int                     `json:"server_model_id"`
+	RamSize         int                     `json:"ram_size"`
+	OS              OrderOS                 `json:"os"`
+	Hdds            map[string]OrderHddSlot `json:"hdds"`
+	Disks           []OrderDiskGroup        `json:"disks"`
+	Uplinks         OrderUplinks            `json:"uplinks"`
+	PublicBandwidth int                     `json:"public_bandwidth"`
+	Ips             int                     `json:"ips"`
+	Ipv6            bool                    `json:"ipv6"`
+}
+
+// ServerUpgrade is the hardware a running server should end up with. The
+// portal places an upgrade order for whatever differs from what it has.
+type ServerUpgrade struct {
+	RamSize         int                     `json:"ram_size"`
+	Hdds            map[string]OrderHddSlot `json:"hdds"`
+	Disks           []OrderDiskGroup        `json:"disks"`
+	Uplinks         OrderUplinks            `json:"uplinks"`
+	PublicBandwidth int                     `json:"public_bandwidth"`
+	Ips             int                     `json:"ips"`
+	Ipv6            bool                    `json:"ipv6"`
+}
+
+// HostConfiguration is the hardware and OS a server actually has, which can
+// differ from what was ordered after upgrades, reinstalls or portal changes.
+type HostConfiguration struct {
+	LocationId      int                     `json:"location_id"`
+	ServerModelId   int                     `json:"server_model_id"`
+	RamSize         int                     `json:"ram_size"`
+	OS              OrderOS                 `json:"os"`
+	Hdds            map[string]OrderHddSlot `json:"hdds"`
+	Disks           []OrderDiskGroup        `json:"disks"`
+	Uplinks         OrderUplinks            `json:"uplinks"`
+	PublicBandwidth int                     `json:"public_bandwidth"`
+	Ips             int                     `json:"ips"`
+	Ipv6            bool                    `json:"ipv6"`
+}
+
+type OrderHost struct {
+	Hostname      string  `json:"hostname"`
+	PrivatePoolId *int    `json:"private_pool_id"`
+	PrivateCidr   *string `json:"private_cidr"`
+}
+
+type OrderOS struct {
+	Name    string `json:"name"`
+	Arch    string `json:"arch"`
+	Version string `json:"version"`
+}
+
+// OrderHddSlot is one drive bay of the chassis. Hdd is nil for an empty bay.
+type OrderHddSlot struct {
+	Interface    int       `json:"interface"`
+	PhysicalSize int       `json:"physical_size"`
+	Hdd          *OrderHdd `json:"hdd"`
+}
+
+type OrderHdd struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+// OrderDiskGroup lays out the drives in the bays listed in Disks as one
+// array with the given RAID level and partitions.
+type OrderDiskGroup struct {
+	Disks      []int            `json:"disks"`
+	Partitions []OrderPartition `json:"partitions"`
+	Raid       int              `json:"raid"`
+}
+
+type OrderPartition struct {
+	Target string `json:"target"`
+	Fs     string `json:"fs"`
+	Size   int    `json:"size"`
+	Fill   bool   `json:"fill"`
+}
+
+type OrderUplinks struct {
+	Public  int `json:"public"`
+	Private int `json:"private"`
+}
+
+// SlotKey is the key of drive bay i in ServerOrder.Hdds.
+func SlotKey(i int) string {
+	return fmt.Sprintf("%d", i)
+}
+
+// CartPayload renders the order as the body AddToCart expects.
+func (o *ServerOrder) CartPayload() (string, error) {
+	b, err := json.Marshal(struct {
+		Data     *ServerOrder `json:"data"`
+		Quantity int          `json:"quantity"`
+	}{o, 1})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,762 @@
+// Package serverscomtest provides an in-memory stand-in for the Servers.com
+// portal API, for running the client and the Terraform provider offline.
+package serverscomtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"servers.com/terraform-provider/serverscom"
+)
+
+// orderProcessing is the status of orders the fake has not fulfilled yet.
+const orderProcessing = 1
+
+// Server is a fake API. Orders move through the same stages as on the real
+// portal, one stage per call to Advance:
+//
+//	order placed -> pending host -> active host
+//	upgrade ordered -> upgrade order completed
+//	cancellation requested -> order cancelled, its pending hosts removed
+//	release scheduled -> host removed
+//
+// With AutoAdvance set, every request advances the state first, so code that
+// polls for a server to become active converges without outside help.
+type Server struct {
+	URL         string
+	Email       string
+	Password    string
+	AutoAdvance bool
+
+	srv *httptest.Server
+	mu  sync.Mutex
+
+	tokens   map[string]bool
+	nextID   int
+	hosts    []*serverscom.Host
+	pending  []*serverscom.Host
+	configs  map[int]*serverscom.HostConfiguration
+	orders   []*order
+	cart     []*cartItem
+	segments []*serverscom.L2Segment
+	records  []*serverscom.Ptr
+	failures []int
+}
+
+type order struct {
+	serverscom.Order
+	items []*cartItem
+
+	// Set on upgrade orders, applied to the host once the order completes.
+	hostID  int
+	upgrade *serverscom.ServerUpgrade
+
+	cancelling bool
+}
+
+type cartItem struct {
+	Id         int                    `json:"id"`
+	Hostnames  []string               `json:"hostnames"`
+	LocationId int                    `json:"location_id"`
+	Config     map[string]interface{} `json:"config"`
+}
+
+// NewServer starts a fake that accepts the given credentials. Call Close
+// when done.
+func NewServer(email, password string) *Server {
+	s := &Server{
+		Email:    email,
+		Password: password,
+		tokens:   map[string]bool{},
+		configs:  map[int]*serverscom.HostConfiguration{},
+		nextID:   1000,
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a client for the fake that is already logged in.
+func (s *Server) Client() (*serverscom.Client, error) {
+	c := serverscom.NewClient(s.URL, s.Email, s.Password)
+	c.MaxRetryWait = time.Millisecond
+	if err := c.Login(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AddHost puts an active host straight into the inventory.
+func (s *Server) AddHost(title string, locationID int) serverscom.Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.newHost(title, &serverscom.HostConfiguration{LocationId: locationID})
+	s.hosts = append(s.hosts, h)
+	return *h
+}
+
+// SetConfiguration changes the hardware of host id as if it had been
+// changed in the portal.
+func (s *Server) SetConfiguration(id int, config serverscom.HostConfiguration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs[id] = &config
+}
+
+// Advance moves every order and host one stage forward.
+func (s *Server) Advance() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+}
+
+// ExpireTokens invalidates all issued tokens, as the portal does after a
+// while, so that the next request gets a 401.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]bool{}
+}
+
+// FailNext makes the next len(statuses) API requests fail with the given
+// status codes, in order, before touching any state.
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+func (s *Server) Hosts() []serverscom.Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyHosts(s.hosts)
+}
+
+func (s *Server) PendingHosts() []serverscom.Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyHosts(s.pending)
+}
+
+func (s *Server) Orders() []serverscom.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.orderList()
+}
+
+func copyHosts(hosts []*serverscom.Host) []serverscom.Host {
+	out := make([]serverscom.Host, len(hosts))
+	for i, h := range hosts {
+		out[i] = *h
+	}
+	return out
+}
+
+func (s *Server) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) newHost(title string, config *serverscom.HostConfiguration) *serverscom.Host {
+	id := s.id()
+	locationID := config.LocationId
+	s.configs[id] = config
+	return &serverscom.Host{
+		Id:          id,
+		Type:        1,
+		Title:       title,
+		ServiceType: 1,
+		Location:    serverscom.Location{Id: locationID, Name: fmt.Sprintf("LOC%d", locationID)},
+		Networks: []serverscom.Network{
+			{Id: s.id(), HostIp: fmt.Sprintf("198.51.100.%d", id%250+1), PoolType: "public", Size: 29, Netmask: "255.255.255.248"},
+			{Id: s.id(), HostIp: fmt.Sprintf("10.0.%d.%d", id/250%250, id%250+1), PoolType: "private", Size: 29, Netmask: "255.255.255.248"},
+		},
+	}
+}
+
+func (s *Server) advance() {
+	var kept []*serverscom.Host
+	for _, h := range s.hosts {
+		if h.ScheduledReleaseAt == nil {
+			kept = append(kept, h)
+		}
+	}
+	s.hosts = kept
+
+	for _, o := range s.orders {
+		if o.cancelling {
+			o.cancelling = false
+			o.Status = serverscom.OrderStatusCancelled
+			o.items = nil
+			s.pending = removeHosts(s.pending, o.Description)
+		}
+	}
+
+	for _, h := range s.pending {
+		s.hosts = append(s.hosts, h)
+	}
+	s.pending = nil
+
+	for _, o := range s.orders {
+		if o.Status != orderProcessing {
+			continue
+		}
+		if o.items == nil {
+			if config := s.configs[o.hostID]; o.upgrade != nil && config != nil {
+				config.RamSize = o.upgrade.RamSize
+				config.Hdds = o.upgrade.Hdds
+				config.Disks = o.upgrade.Disks
+				config.Uplinks = o.upgrade.Uplinks
+				config.PublicBandwidth = o.upgrade.PublicBandwidth
+				config.Ips = o.upgrade.Ips
+				config.Ipv6 = o.upgrade.Ipv6
+			}
+			o.Status = serverscom.OrderStatusCompleted
+			continue
+		}
+		for _, item := range o.items {
+			for _, hostname := range item.Hostnames {
+				s.pending = append(s.pending, s.newHost(hostname, item.configuration()))
+			}
+		}
+		o.items = nil
+	}
+
+	for _, seg := range s.segments {
+		if seg.Status == "pending" {
+			seg.Status = "active"
+		}
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		writeError(w, status, http.StatusText(status), nil)
+		return
+	}
+	if r.URL.Path == "/p/login_token" {
+		s.login(w, r)
+		return
+	}
+	if r.Header.Get("X-User-Email") != s.Email || !s.tokens[r.Header.Get("X-User-Token")] {
+		writeError(w, http.StatusUnauthorized, "Invalid token", nil)
+		return
+	}
+	if s.AutoAdvance {
+		s.advance()
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	path := strings.TrimRight(r.URL.Path, "/")
+	switch {
+	case path == "/rest/hosts" && r.Method == "GET":
+		hosts := s.hosts
+		if title := r.URL.Query().Get("title"); title != "" {
+			hosts = nil
+			for _, h := range s.hosts {
+				if h.Title == title {
+					hosts = append(hosts, h)
+				}
+			}
+		}
+		writeList(w, r, copyHosts(hosts))
+	case path == "/rest/hosts_pending" && r.Method == "GET":
+		writeList(w, r, copyHosts(s.pending))
+	case strings.HasPrefix(path, "/rest/hosts/") && strings.HasSuffix(path, "/schedule_release") && r.Method == "POST":
+		s.scheduleRelease(w, strings.TrimSuffix(strings.TrimPrefix(path, "/rest/hosts/"), "/schedule_release"), body)
+	case strings.HasPrefix(path, "/rest/hosts/") && strings.HasSuffix(path, "/configuration") && r.Method == "GET":
+		s.hostConfiguration(w, strings.TrimSuffix(strings.TrimPrefix(path, "/rest/hosts/"), "/configuration"))
+	case strings.HasPrefix(path, "/rest/hosts/") && strings.HasSuffix(path, "/upgrade") && r.Method == "POST":
+		s.upgradeHost(w, strings.TrimSuffix(strings.TrimPrefix(path, "/rest/hosts/"), "/upgrade"), body)
+	case strings.HasPrefix(path, "/rest/hosts/") && strings.HasSuffix(path, "/reinstall") && r.Method == "POST":
+		s.reinstallHost(w, strings.TrimSuffix(strings.TrimPrefix(path, "/rest/hosts/"), "/reinstall"), body)
+	case strings.HasPrefix(path, "/rest/hosts/") && r.Method == "PUT":
+		s.updateHost(w, strings.TrimPrefix(path, "/rest/hosts/"), body)
+	case path == "/rest/server_cart_items" && r.Method == "GET":
+		writeList(w, r, s.cart)
+	case path == "/rest/server_cart_items" && r.Method == "POST":
+		s.addToCart(w, body)
+	case strings.HasPrefix(path, "/rest/server_cart_items/") && r.Method == "DELETE":
+		s.removeFromCart(w, strings.TrimPrefix(path, "/rest/server_cart_items/"))
+	case path == "/rest/orders" && r.Method == "GET":
+		writeList(w, r, s.orderList())
+	case strings.HasPrefix(path, "/rest/orders/") && strings.HasSuffix(path, "/cancel") && r.Method == "POST":
+		s.cancelOrder(w, strings.TrimSuffix(strings.TrimPrefix(path, "/rest/orders/"), "/cancel"))
+	case strings.HasPrefix(path, "/rest/hosts_pending/") && strings.HasSuffix(path, "/cancel") && r.Method == "POST":
+		s.cancelPending(w, strings.TrimSuffix(strings.TrimPrefix(path, "/rest/hosts_pending/"), "/cancel"))
+	case path == "/rest/orders" && r.Method == "POST":
+		s.checkout(w)
+	case path == "/rest/l2_segments" && r.Method == "GET":
+		segments := make([]serverscom.L2Segment, len(s.segments))
+		for i, seg := range s.segments {
+			segments[i] = *seg
+		}
+		writeList(w, r, segments)
+	case path == "/rest/l2_segments" && r.Method == "POST":
+		s.saveSegment(w, nil, body)
+	case strings.HasPrefix(path, "/rest/l2_segments/") && r.Method == "PUT":
+		seg := s.findSegment(strings.TrimPrefix(path, "/rest/l2_segments/"))
+		if seg == nil {
+			writeError(w, http.StatusNotFound, "L2 segment not found", nil)
+			return
+		}
+		s.saveSegment(w, seg, body)
+	case strings.HasPrefix(path, "/rest/l2_segments/") && r.Method == "DELETE":
+		s.deleteSegment(w, strings.TrimPrefix(path, "/rest/l2_segments/"))
+	case path == "/rest/dns/records" && r.Method == "GET":
+		records := make([]serverscom.Ptr, len(s.records))
+		for i, rec := range s.records {
+			records[i] = *rec
+		}
+		writeList(w, r, records)
+	case path == "/rest/dns/records" && r.Method == "POST":
+		s.addRecord(w, body)
+	case strings.HasPrefix(path, "/rest/dns/records/") && r.Method == "DELETE":
+		s.deleteRecord(w, strings.TrimLeft(strings.TrimPrefix(path, "/rest/dns/records"), "/"))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No route for %s %s", r.Method, r.URL.Path), nil)
+	}
+}
+
+func (s *Server) orderList() []serverscom.Order {
+	out := make([]serverscom.Order, len(s.orders))
+	for i, o := range s.orders {
+		out[i] = o.Order
+	}
+	return out
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("email") != s.Email || r.PostForm.Get("pwd") != s.Password {
+		writeError(w, http.StatusUnauthorized, "Invalid email or password", nil)
+		return
+	}
+	token := fmt.Sprintf("token-%d", s.id())
+	s.tokens[token] = true
+	writeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+func (s *Server) scheduleRelease(w http.ResponseWriter, id string, body []byte) {
+	var confirm struct {
+		Token string `json:"token"`
+	}
+	if json.Unmarshal(body, &confirm) != nil || confirm.Token == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed", map[string][]string{"token": {"can't be blank"}})
+		return
+	}
+	h := s.findHost(id)
+	if h == nil {
+		writeError(w, http.StatusNotFound, "Host not found", nil)
+		return
+	}
+	if h.ScheduledReleaseAt != nil {
+		writeError(w, http.StatusConflict, "Release is already scheduled", nil)
+		return
+	}
+	h.ScheduledReleaseAt = time.Now().UTC().Format(time.RFC3339)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": *h})
+}
+
+func (s *Server) updateHost(w http.ResponseWriter, id string, body []byte) {
+	var req struct {
+		Title string `json:"title"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Title == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed", map[string][]string{"title": {"can't be blank"}})
+		return
+	}
+	h := s.findHost(id)
+	if h == nil {
+		writeError(w, http.StatusNotFound, "Host not found", nil)
+		return
+	}
+	if h.Title != req.Title && s.hostnameTaken(req.Title) {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed", map[string][]string{"title": {"has already been taken"}})
+		return
+	}
+	h.Title = req.Title
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": *h})
+}
+
+func (s *Server) findHost(id string) *serverscom.Host {
+	for _, h := range s.hosts {
+		if strconv.Itoa(h.Id) == id {
+			return h
+		}
+	}
+	return nil
+}
+
+// upgradeHost places an upgrade order, which completes on the next advance.
+func (s *Server) upgradeHost(w http.ResponseWriter, id string, body []byte) {
+	var req struct {
+		Data *serverscom.ServerUpgrade `json:"data"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Data == nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed", map[string][]string{"data": {"can't be blank"}})
+		return
+	}
+	h := s.findHost(id)
+	if h == nil {
+		writeError(w, http.StatusNotFound, "Host not found", nil)
+		return
+	}
+	if h.ScheduledReleaseAt != nil {
+		writeError(w, http.StatusConflict, "Host is scheduled for release", nil)
+		return
+	}
+	o := &order{
+		Order: serverscom.Order{
+			Id:          s.id(),
+			Status:      orderProcessing,
+			CreatedTime: time.Now().UTC().Format(time.RFC3339),
+			Currency:    "USD",
+			Description: []string{fmt.Sprintf("Upgrade of %s", h.Title)},
+		},
+		hostID:  h.Id,
+		upgrade: req.Data,
+	}
+	s.orders = append(s.orders, o)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"data": o.Order})
+}
+
+func (s *Server) reinstallHost(w http.ResponseWriter, id string, body []byte) {
+	var req struct {
+		OS *serverscom.OrderOS `json:"os"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.OS == nil || req.OS.Name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed", map[string][]string{"os": {"can't be blank"}})
+		return
+	}
+	h := s.findHost(id)
+	if h == nil {
+		writeError(w, http.StatusNotFound, "Host not found", nil)
+		return
+	}
+	if h.ScheduledReleaseAt != nil {
+		writeError(w, http.StatusConflict, "Host is scheduled for release", nil)
+		return
+	}
+	if config := s.configs[h.Id]; config != nil {
+		config.OS = *req.OS
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": *h})
+}
+
+func (s *Server) hostConfiguration(w http.ResponseWriter, id string) {
+	h := s.findHost(id)
+	if h == nil {
+		writeError(w, http.StatusNotFound, "Host not found", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": s.configs[h.Id]})
+}
+
+// configuration is the hardware the servers of a cart item are delivered with.
+func (item *cartItem) configuration() *serverscom.HostConfiguration {
+	config := &serverscom.HostConfiguration{}
+	if b, err := json.Marshal(item.Config); err == nil {
+		json.Unmarshal(b, config)
+	}
+	config.LocationId = item.LocationId
+	return config
+}
+
+// addToCart accepts the payload the provider renders from a server config:
+//
+//	{"data": {"hosts": [{"hostname": "..."}], "location_id": 1, ...}, "quantity": 1}
+func (s *Server) addToCart(w http.ResponseWriter, body []byte) {
+	var payload struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Data == nil {
+		writeError(w, http.StatusBadRequest, "Malformed cart item", nil)
+		return
+	}
+	fields := map[string][]string{}
+	for _, field := range []string{"location_id", "server_model_id", "ram_size", "os"} {
+		if payload.Data[field] == nil {
+			fields[field] = append(fields[field], "can't be blank")
+		}
+	}
+	item := &cartItem{Config: payload.Data}
+	if loc, ok := payload.Data["location_id"].(float64); ok {
+		item.LocationId = int(loc)
+	}
+	hosts, _ := payload.Data["hosts"].([]interface{})
+	for _, h := range hosts {
+		m, _ := h.(map[string]interface{})
+		hostname, _ := m["hostname"].(string)
+		if hostname == "" {
+			fields["hosts"] = append(fields["hosts"], "hostname can't be blank")
+			continue
+		}
+		if s.hostnameTaken(hostname) {
+			fields["hosts"] = append(fields["hosts"], fmt.Sprintf("hostname %s is already taken", hostname))
+		}
+		item.Hostnames = append(item.Hostnames, hostname)
+	}
+	if len(hosts) == 0 {
+		fields["hosts"] = append(fields["hosts"], "can't be blank")
+	}
+	if len(fields) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed", fields)
+		return
+	}
+	item.Id = s.id()
+	s.cart = append(s.cart, item)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"data": item})
+}
+
+func (s *Server) hostnameTaken(hostname string) bool {
+	for _, h := range append(append([]*serverscom.Host{}, s.hosts...), s.pending...) {
+		if h.Title == hostname {
+			return true
+		}
+	}
+	for _, o := range s.orders {
+		for _, item := range o.items {
+			for _, name := range item.Hostnames {
+				if name == hostname {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) removeFromCart(w http.ResponseWriter, id string) {
+	for i, item := range s.cart {
+		if strconv.Itoa(item.Id) == id {
+			s.cart = append(s.cart[:i], s.cart[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Cart item not found", nil)
+}
+
+func (s *Server) checkout(w http.ResponseWriter) {
+	if len(s.cart) == 0 {
+		writeError(w, http.StatusUnprocessableEntity, "Cart is empty", nil)
+		return
+	}
+	o := &order{
+		Order: serverscom.Order{
+			Id:          s.id(),
+			Status:      orderProcessing,
+			CreatedTime: time.Now().UTC().Format(time.RFC3339),
+			Currency:    "USD",
+		},
+		items: s.cart,
+	}
+	for _, item := range s.cart {
+		o.Description = append(o.Description, item.Hostnames...)
+	}
+	s.cart = nil
+	s.orders = append(s.orders, o)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"data": o.Order})
+}
+
+// cancelOrder accepts the cancellation of an open order. The order is
+// cancelled on the next advance.
+func (s *Server) cancelOrder(w http.ResponseWriter, id string) {
+	for _, o := range s.orders {
+		if strconv.Itoa(o.Id) != id {
+			continue
+		}
+		if o.Status != orderProcessing {
+			writeError(w, http.StatusConflict, fmt.Sprintf("Order in status %d cannot be cancelled", o.Status), nil)
+			return
+		}
+		o.cancelling = true
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": o.Order})
+		return
+	}
+	writeError(w, http.StatusNotFound, "Order not found", nil)
+}
+
+func (s *Server) cancelPending(w http.ResponseWriter, id string) {
+	for i, h := range s.pending {
+		if strconv.Itoa(h.Id) == id {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": *h})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Host not found", nil)
+}
+
+func removeHosts(hosts []*serverscom.Host, titles []string) []*serverscom.Host {
+	var kept []*serverscom.Host
+	for _, h := range hosts {
+		removed := false
+		for _, title := range titles {
+			if h.Title == title {
+				removed = true
+			}
+		}
+		if !removed {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
+func (s *Server) findSegment(id string) *serverscom.L2Segment {
+	for _, seg := range s.segments {
+		if strconv.Itoa(seg.Id) == id {
+			return seg
+		}
+	}
+	return nil
+}
+
+func (s *Server) saveSegment(w http.ResponseWriter, seg *serverscom.L2Segment, body []byte) {
+	var req serverscom.L2Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Malformed L2 segment", nil)
+		return
+	}
+	var hosts []serverscom.L2Host
+	for _, rh := range req.Hosts {
+		var found *serverscom.Host
+		for _, h := range s.hosts {
+			if h.Id == rh.Id {
+				found = h
+			}
+		}
+		if found == nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation failed",
+				map[string][]string{"hosts": {fmt.Sprintf("host %d is not active", rh.Id)}})
+			return
+		}
+		if found.Location.Id != req.LocationId {
+			writeError(w, http.StatusUnprocessableEntity, "Validation failed",
+				map[string][]string{"location_id": {fmt.Sprintf("host %s is in another location", found.Title)}})
+			return
+		}
+		hosts = append(hosts, serverscom.L2Host{Id: found.Id, Mode: rh.Mode, Title: found.Title})
+	}
+	if seg == nil {
+		seg = &serverscom.L2Segment{Id: s.id()}
+		s.segments = append(s.segments, seg)
+	}
+	seg.Name = req.Name
+	seg.Type = req.Type
+	seg.Hosts = hosts
+	seg.Location = map[string]int{"id": req.LocationId}
+	seg.Status = "pending"
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": *seg})
+}
+
+func (s *Server) deleteSegment(w http.ResponseWriter, id string) {
+	for i, seg := range s.segments {
+		if strconv.Itoa(seg.Id) == id {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "L2 segment not found", nil)
+}
+
+func (s *Server) addRecord(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Data string `json:"data"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.Data == "" || req.Name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed",
+			map[string][]string{"data": {"can't be blank"}, "name": {"can't be blank"}})
+		return
+	}
+	rec := &serverscom.Ptr{Id: s.id(), DomainId: 1, Type: "PTR", Name: req.Name, Data: req.Data, Ttl: 3600}
+	s.records = append(s.records, rec)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"data": *rec})
+}
+
+func (s *Server) deleteRecord(w http.ResponseWriter, id string) {
+	for i, rec := range s.records {
+		if strconv.Itoa(rec.Id) == id {
+			s.records = append(s.records[:i], s.records[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Record not found", nil)
+}
+
+// writeList serves items as {"data": [...]}, paged with a Link header and a
+// meta object when the request asks for page and per_page.
+func writeList(w http.ResponseWriter, r *http.Request, items interface{}) {
+	data, _ := json.Marshal(items)
+	var all []json.RawMessage
+	json.Unmarshal(data, &all)
+	if all == nil {
+		all = []json.RawMessage{}
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if page < 1 || perPage < 1 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": all})
+		return
+	}
+	lastPage := (len(all) + perPage - 1) / perPage
+	if lastPage == 0 {
+		lastPage = 1
+	}
+	start, end := (page-1)*perPage, page*perPage
+	if start > len(all) {
+		start = len(all)
+	}
+	if end > len(all) {
+		end = len(all)
+	}
+	if page < lastPage {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(page+1))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, q.Encode()))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": all[start:end],
+		"meta": map[string]int{"current_page": page, "last_page": lastPage},
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string, fields map[string][]string) {
+	body := map[string]interface{}{"message": message}
+	if len(fields) > 0 {
+		for _, msgs := range fields {
+			sort.Strings(msgs)
+		}
+		body["errors"] = fields
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", strconv.FormatInt(time.Now().UnixNano(), 36))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
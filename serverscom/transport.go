@@ -0,0 +1,71 @@
+package serverscom
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+)
+
+// TransportConfig describes how to reach the API. The zero value behaves
+// like http.DefaultTransport: system CAs and proxies from the environment.
+type TransportConfig struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+
+	// ClientCertFile and ClientKeyFile hold a PEM client certificate for
+	// gateways that require mutual TLS. Both or neither must be set.
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// InsecureSkipVerify disables server certificate checks. Only meant for
+	// lab stand-ins of the API.
+	InsecureSkipVerify bool
+
+	// ProxyURL overrides the HTTP(S)_PROXY environment variables.
+	ProxyURL string
+}
+
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New(fmt.Sprintf("No certificates found in %s.", cfg.CAFile))
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return nil, errors.New("Client certificate and key must be set together.")
+	}
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if cfg.ProxyURL != "" {
+		proxy, err := neturl.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport, nil
+}